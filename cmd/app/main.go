@@ -4,6 +4,8 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/antonmisa/cliurlfetcher/internal/app"
 	"github.com/antonmisa/cliurlfetcher/internal/config"
@@ -13,8 +15,139 @@ func main() {
 	var prepare bool
 	flag.BoolVar(&prepare, "prepare", false, "creating default environment and config")
 
-	var filePath string
-	flag.StringVar(&filePath, "filepath", "", "path to file with urls")
+	var filePaths stringsFlag
+	flag.Var(&filePaths, "filepath", "path or glob pattern of file with urls, - for stdin, could be repeated; "+
+		"the same as positional arguments")
+
+	var inputFormat string
+	flag.StringVar(&inputFormat, "input-format", "", "input file format: auto, text, csv or jsonl")
+
+	var outputFormat string
+	flag.StringVar(&outputFormat, "output-format", "", "output format: text, jsonl, csv or tsv")
+
+	var columns string
+	flag.StringVar(&columns, "columns", "", "comma separated columns of csv and tsv output: "+
+		"id, url, method, tags, status, expected_status, content_length, retries, duration_ms, error, content")
+
+	var outputTemplate string
+	flag.StringVar(&outputTemplate, "output-template", "", "text/template to render every task, inline or @path to file")
+
+	var ordered bool
+	flag.BoolVar(&ordered, "ordered", false, "write results in input order")
+
+	var reorderWindow int
+	flag.IntVar(&reorderWindow, "reorder-window", 0, "max number of results buffered to restore input order")
+
+	var readLimit int64
+	flag.Int64Var(&readLimit, "read-limit", 0, "bytes of the response body kept in the report")
+
+	var headers stringsFlag
+	flag.Var(&headers, "header", "header \"Name: value\" sent with every request, could be repeated")
+
+	var userAgent string
+	flag.StringVar(&userAgent, "user-agent", "", "User-Agent of requests not having own")
+
+	var cookies bool
+	flag.BoolVar(&cookies, "cookies", false, "keep cookies received and send them back to the same host")
+
+	var proxy string
+	flag.StringVar(&proxy, "proxy", "", "proxy url http://, https:// or socks5:// of the hosts not matching proxies rules of config")
+
+	var caFiles stringsFlag
+	flag.Var(&caFiles, "ca-file", "PEM bundle of CA trusted in addition to the system roots, could be repeated")
+
+	var certFile, keyFile string
+	flag.StringVar(&certFile, "cert", "", "PEM client certificate for mTLS")
+	flag.StringVar(&keyFile, "key", "", "PEM key of the client certificate")
+
+	var tlsMinVersion string
+	flag.StringVar(&tlsMinVersion, "tls-min-version", "", "minimum TLS version: 1.0, 1.1, 1.2 or 1.3")
+
+	var insecure bool
+	flag.BoolVar(&insecure, "insecure", false, "skip verification of server certificates, never use it in production")
+
+	var certWarnDays int
+	flag.IntVar(&certWarnDays, "cert-warn-days", 0, "flag certificates expiring within the days and exit with code 2")
+
+	var noFollow bool
+	flag.BoolVar(&noFollow, "no-follow", false, "do not follow redirects, the redirect response is the result")
+
+	var maxRedirects int
+	flag.IntVar(&maxRedirects, "max-redirects", 0, "max redirects followed")
+
+	var sameHostRedirects bool
+	flag.BoolVar(&sameHostRedirects, "same-host-redirects", false, "follow redirects to the same host only")
+
+	var stripAuth bool
+	flag.BoolVar(&stripAuth, "strip-auth", false, "drop Authorization and Cookie headers on redirects to another host")
+
+	var timeout, connectTimeout, tlsTimeout, headerTimeout, idleTimeout time.Duration
+	flag.DurationVar(&timeout, "timeout", 0, "timeout of a single attempt with the body read")
+	flag.DurationVar(&connectTimeout, "connect-timeout", 0, "timeout of dns lookup and connect to all addresses of the host")
+	flag.DurationVar(&tlsTimeout, "tls-timeout", 0, "timeout of TLS handshake")
+	flag.DurationVar(&headerTimeout, "header-timeout", 0, "timeout of waiting for the response after the request is written")
+	flag.DurationVar(&idleTimeout, "idle-timeout", 0, "longest pause while reading the body")
+
+	var bodyDir string
+	flag.StringVar(&bodyDir, "body-dir", "", "directory to save complete response bodies to")
+
+	var maxAttempts int
+	flag.IntVar(&maxAttempts, "max-attempts", 0, "attempts of the task not having own max_retries")
+
+	var retryStatuses string
+	flag.StringVar(&retryStatuses, "retry-statuses", "", "comma separated retryable status codes, ranges and classes, e.g. 429,500-504,5xx")
+
+	var retryErrors string
+	flag.StringVar(&retryErrors, "retry-errors", "", "comma separated error categories to retry on: "+
+		"auth_error, dns_failure, connection_refused, tls_error, timeout, too_many_redirects, other; all but too_many_redirects by default, none disables them")
+
+	var retryWaitMin, retryWaitMax time.Duration
+	flag.DurationVar(&retryWaitMin, "retry-wait-min", 0, "minimal wait between attempts")
+	flag.DurationVar(&retryWaitMax, "retry-wait-max", 0, "maximal wait between attempts")
+
+	var retryBackoff string
+	flag.StringVar(&retryBackoff, "retry-backoff", "", "wait between attempts: exponential, constant, linear, "+
+		"full_jitter, equal_jitter or decorrelated_jitter")
+
+	var retrySeed int64
+	flag.Int64Var(&retrySeed, "retry-seed", 0, "seed of the backoff jitter, the same seed gives the same waits")
+
+	var maxRetryAfter time.Duration
+	flag.DurationVar(&maxRetryAfter, "max-retry-after", 0, "longest honored Retry-After wait, longer ones are truncated")
+
+	var abandonLongRetryAfter bool
+	flag.BoolVar(&abandonLongRetryAfter, "abandon-long-retry-after", false,
+		"give the task up instead of truncating the wait when Retry-After is too long")
+
+	var retryNonIdempotent bool
+	flag.BoolVar(&retryNonIdempotent, "retry-non-idempotent", false, "retry POST and PATCH requests too")
+
+	var hostMaxInFlight int
+	flag.IntVar(&hostMaxInFlight, "host-max-in-flight", 0, "max requests in flight to the same host")
+
+	var hostDelay time.Duration
+	flag.DurationVar(&hostDelay, "host-delay", 0, "min delay between requests to the same host")
+
+	var rps float64
+	flag.Float64Var(&rps, "rps", 0, "max requests per second of all hosts")
+
+	var burst int
+	flag.IntVar(&burst, "burst", 0, "max requests at once within --rps")
+
+	var hostRPS float64
+	flag.Float64Var(&hostRPS, "host-rps", 0, "max requests per second to the same host")
+
+	var hostBurst int
+	flag.IntVar(&hostBurst, "host-burst", 0, "max requests at once to the same host within --host-rps")
+
+	var breakerFailures int
+	flag.IntVar(&breakerFailures, "breaker-failures", 0, "failures in a row opening circuit of the host, 0 means no breaker")
+
+	var breakerCooldown time.Duration
+	flag.DurationVar(&breakerCooldown, "breaker-cooldown", 0, "wait of the open circuit before a probe request")
+
+	var limitByDomain bool
+	flag.BoolVar(&limitByDomain, "limit-by-domain", false, "apply host limits to registered domains, e.g. example.co.uk")
 
 	flag.Parse()
 
@@ -34,6 +167,200 @@ func main() {
 		log.Fatalf("Config error: %s", err)
 	}
 
+	// Command line flags take precedence over config
+	if inputFormat != "" {
+		cfg.Input.Format = inputFormat
+	}
+
+	if outputFormat != "" {
+		cfg.Output.Format = outputFormat
+	}
+
+	if outputTemplate != "" {
+		cfg.Output.Template = outputTemplate
+	}
+
+	if ordered {
+		cfg.Output.Ordered = true
+	}
+
+	if reorderWindow > 0 {
+		cfg.Output.ReorderWindow = reorderWindow
+	}
+
+	if readLimit > 0 {
+		cfg.Fetcher.ReadLimit = readLimit
+	}
+
+	if len(headers) > 0 {
+		cfg.Fetcher.Headers = append(cfg.Fetcher.Headers, headers...)
+	}
+
+	if userAgent != "" {
+		cfg.Fetcher.UserAgent = userAgent
+	}
+
+	if cookies {
+		cfg.Fetcher.Cookies = true
+	}
+
+	// the last rule catches the rest
+	if proxy != "" {
+		cfg.Proxies = append(cfg.Proxies, config.ProxyRule{Pattern: "*", URL: proxy})
+	}
+
+	if len(caFiles) > 0 {
+		cfg.TLS.CAFiles = append(cfg.TLS.CAFiles, caFiles...)
+	}
+
+	if certFile != "" || keyFile != "" {
+		cfg.TLS.CertFile, cfg.TLS.KeyFile = certFile, keyFile
+	}
+
+	if tlsMinVersion != "" {
+		cfg.TLS.MinVersion = tlsMinVersion
+	}
+
+	if insecure {
+		cfg.TLS.InsecureSkipVerify = true
+	}
+
+	if certWarnDays > 0 {
+		cfg.Fetcher.CertWarnDays = certWarnDays
+	}
+
+	if noFollow {
+		cfg.Redirects.NoFollow = true
+	}
+
+	if maxRedirects > 0 {
+		cfg.Redirects.MaxHops = maxRedirects
+	}
+
+	if sameHostRedirects {
+		cfg.Redirects.SameHost = true
+	}
+
+	if stripAuth {
+		cfg.Redirects.StripAuth = true
+	}
+
+	if timeout > 0 {
+		cfg.Timeouts.Total = timeout
+	}
+
+	if connectTimeout > 0 {
+		cfg.Timeouts.Connect = connectTimeout
+	}
+
+	if tlsTimeout > 0 {
+		cfg.Timeouts.TLSHandshake = tlsTimeout
+	}
+
+	if headerTimeout > 0 {
+		cfg.Timeouts.ResponseHeader = headerTimeout
+	}
+
+	if idleTimeout > 0 {
+		cfg.Timeouts.BodyIdle = idleTimeout
+	}
+
+	if bodyDir != "" {
+		cfg.Fetcher.BodyDir = bodyDir
+	}
+
+	if columns != "" {
+		cfg.Output.Columns = strings.Split(columns, ",")
+	}
+
+	if maxAttempts > 0 {
+		cfg.Retry.MaxAttempts = maxAttempts
+	}
+
+	if retryStatuses != "" {
+		cfg.Retry.Statuses = strings.Split(retryStatuses, ",")
+	}
+
+	if retryErrors != "" {
+		cfg.Retry.Errors = strings.Split(retryErrors, ",")
+	}
+
+	if retryWaitMin > 0 {
+		cfg.Retry.WaitMin = retryWaitMin
+	}
+
+	if retryWaitMax > 0 {
+		cfg.Retry.WaitMax = retryWaitMax
+	}
+
+	if retryBackoff != "" {
+		cfg.Retry.Backoff = retryBackoff
+	}
+
+	if retrySeed != 0 {
+		cfg.Retry.Seed = retrySeed
+	}
+
+	if maxRetryAfter > 0 {
+		cfg.Retry.MaxRetryAfter = maxRetryAfter
+	}
+
+	if abandonLongRetryAfter {
+		cfg.Retry.AbandonLongRetryAfter = true
+	}
+
+	if retryNonIdempotent {
+		cfg.Retry.NonIdempotent = true
+	}
+
+	if hostMaxInFlight > 0 {
+		cfg.Limits.MaxInFlight = hostMaxInFlight
+	}
+
+	if hostDelay > 0 {
+		cfg.Limits.Delay = hostDelay
+	}
+
+	if rps > 0 {
+		cfg.Limits.GlobalRPS = rps
+	}
+
+	if burst > 0 {
+		cfg.Limits.GlobalBurst = burst
+	}
+
+	if hostRPS > 0 {
+		cfg.Limits.RPS = hostRPS
+	}
+
+	if hostBurst > 0 {
+		cfg.Limits.Burst = hostBurst
+	}
+
+	if limitByDomain {
+		cfg.Limits.ByDomain = true
+	}
+
+	if breakerFailures > 0 {
+		cfg.Breaker.Failures = breakerFailures
+	}
+
+	if breakerCooldown > 0 {
+		cfg.Breaker.Cooldown = breakerCooldown
+	}
+
 	// Run
-	app.Run(cfg, filePath)
+	os.Exit(app.Run(cfg, append(filePaths, flag.Args()...)))
+}
+
+// stringsFlag collects values of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
 }
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,14 +11,28 @@ import (
 
 	"github.com/antonmisa/cliurlfetcher/internal/config"
 	cli "github.com/antonmisa/cliurlfetcher/internal/controller"
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/auth"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/fetcher"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase/fetchprocessor"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase/filereader"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase/filewriter"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/limiter"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase/queue"
+	"github.com/antonmisa/cliurlfetcher/pkg/httpheader"
 	"github.com/antonmisa/cliurlfetcher/pkg/logger"
 )
 
-func Run(cfg *config.Config, filePath string) {
+// authTokenTimeout bounds requests to OAuth2 token endpoints
+const authTokenTimeout = 30 * time.Second
+
+// ExitCertExpiring is returned by Run when a certificate expires within the warning threshold.
+const ExitCertExpiring = 2
+
+// Run fetches urls from the input files, see openInputs for paths handling.
+// The exit code of the process is returned.
+func Run(cfg *config.Config, filePaths []string) int {
 	op := "app - Run"
 
 	l, err := logger.New(cfg.Log.Path, cfg.Log.Level)
@@ -25,11 +40,122 @@ func Run(cfg *config.Config, filePath string) {
 		l.Fatal(fmt.Errorf("%s - logger.New: %w", op, err))
 	}
 
-	fh, err := os.OpenFile(filePath, os.O_RDONLY, 0444)
+	format, err := filereader.ParseFormat(cfg.Input.Format)
+	if err != nil {
+		l.Fatal("%s - filereader.ParseFormat: %v", op, err)
+	}
+
+	outFormat, err := filewriter.ParseFormat(cfg.Output.Format)
+	if err != nil {
+		l.Fatal("%s - filewriter.ParseFormat: %v", op, err)
+	}
+
+	// template defines the output by itself
+	if cfg.Output.Template != "" {
+		outFormat = filewriter.FormatTemplate
+	}
+
+	formatter, err := filewriter.NewFormatter(outFormat, filewriter.FormatterOptions{
+		Columns:  cfg.Output.Columns,
+		Template: cfg.Output.Template,
+	})
+	if err != nil {
+		l.Fatal("%s - filewriter.NewFormatter: %v", op, err)
+	}
+
+	bodyNaming, err := fetcher.ParseBodyNaming(cfg.Fetcher.BodyNaming)
+	if err != nil {
+		l.Fatal("%s - fetcher.ParseBodyNaming: %v", op, err)
+	}
+
+	headers, err := httpheader.ParseLines(cfg.Fetcher.Headers)
+	if err != nil {
+		l.Fatal("%s - httpheader.ParseLines: %v", op, err)
+	}
+
+	proxies := make([]fetcher.ProxyRule, 0, len(cfg.Proxies))
+	for _, p := range cfg.Proxies {
+		proxies = append(proxies, fetcher.ProxyRule{Pattern: p.Pattern, Proxy: p.URL})
+	}
+
+	tlsOpts := newTLSOptions(cfg.TLS)
+
+	// tokens are got through the same proxies and tls settings as the urls
+	authProvider, err := newAuth(cfg.Auth, proxies, tlsOpts)
+	if err != nil {
+		l.Fatal("%s - newAuth: %v", op, err)
+	}
+
+	retryStatuses, err := fetcher.ParseStatusRanges(cfg.Retry.Statuses)
+	if err != nil {
+		l.Fatal("%s - fetcher.ParseStatusRanges: %v", op, err)
+	}
+
+	retryErrors, err := fetcher.ParseErrorCategories(cfg.Retry.Errors)
+	if err != nil {
+		l.Fatal("%s - fetcher.ParseErrorCategories: %v", op, err)
+	}
+
+	backoff, err := fetcher.ParseBackoffStrategy(cfg.Retry.Backoff)
+	if err != nil {
+		l.Fatal("%s - fetcher.ParseBackoffStrategy: %v", op, err)
+	}
+
+	ftchr, err := fetcher.New(fetcher.Options{
+		ReadLimit:  cfg.Fetcher.ReadLimit,
+		BodyDir:    cfg.Fetcher.BodyDir,
+		BodyNaming: bodyNaming,
+		Retry: fetcher.RetryPolicy{
+			Statuses:      retryStatuses,
+			Errors:        retryErrors,
+			NonIdempotent: cfg.Retry.NonIdempotent,
+			WaitMin:       cfg.Retry.WaitMin,
+			WaitMax:       cfg.Retry.WaitMax,
+
+			MaxRetryAfter:         cfg.Retry.MaxRetryAfter,
+			AbandonLongRetryAfter: cfg.Retry.AbandonLongRetryAfter,
+		},
+		Backoff: fetcher.NewBackoff(backoff, cfg.Retry.Seed),
+		Breaker: fetcher.BreakerOptions{
+			Failures: cfg.Breaker.Failures,
+			Cooldown: cfg.Breaker.Cooldown,
+		},
+		Headers:   headers,
+		UserAgent: cfg.Fetcher.UserAgent,
+		Cookies:   cfg.Fetcher.Cookies,
+		Auth:      authProvider,
+		Proxies:   proxies,
+		TLS:       tlsOpts,
+
+		CertWarnDays: cfg.Fetcher.CertWarnDays,
+		Redirects: fetcher.RedirectOptions{
+			NoFollow:  cfg.Redirects.NoFollow,
+			MaxHops:   cfg.Redirects.MaxHops,
+			SameHost:  cfg.Redirects.SameHost,
+			StripAuth: cfg.Redirects.StripAuth,
+		},
+		Timeout: cfg.Timeouts.Total,
+		Timeouts: entity.Timeouts{
+			Connect:        cfg.Timeouts.Connect,
+			TLSHandshake:   cfg.Timeouts.TLSHandshake,
+			ResponseHeader: cfg.Timeouts.ResponseHeader,
+			BodyIdle:       cfg.Timeouts.BodyIdle,
+		},
+	}, l)
+	if err != nil {
+		l.Fatal("%s - fetcher.New: %v", op, err)
+	}
+
+	lim, err := newLimiter(cfg.Limits, cfg.Retry.MaxRetryAfter)
 	if err != nil {
-		l.Fatal("%s could't read  file %s: %v", op, filePath, err)
+		l.Fatal("%s - newLimiter: %v", op, err)
 	}
-	defer fh.Close()
+
+	sources, closeInputs, err := openInputs(filePaths)
+	if err != nil {
+		l.Fatal("%s - openInputs: %v", op, err)
+	}
+	defer closeInputs()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -47,9 +173,17 @@ func Run(cfg *config.Config, filePath string) {
 	in := queue.New()
 	out := queue.New()
 
-	fr := filereader.New(ctx, fh, in, l)
-	fw := filewriter.New(ctx, os.Stdout, out, l)
-	proc := fetchprocessor.New(ctx, cfg.NumberOfWorkers, in, out, l)
+	fr := filereader.New(ctx, sources, format, cfg.Retry.MaxAttempts, in, l)
+	var results usecase.QueueReader = out
+	if cfg.Output.Ordered {
+		results = filewriter.NewOrderedReader(out, cfg.Output.ReorderWindow)
+	}
+
+	certs := filewriter.NewCertWatch(results)
+	results = certs
+
+	fw := filewriter.New(ctx, os.Stdout, formatter, results, l)
+	proc := fetchprocessor.New(ctx, cfg.NumberOfWorkers, ftchr, lim, in, out, l)
 
 	ctrl := cli.New(ctx, in, out, fr, fw, proc, l)
 
@@ -59,5 +193,128 @@ func Run(cfg *config.Config, filePath string) {
 
 	ctrl.Start()
 
+	reportBreakers(ftchr.BreakerStats(), l)
+
 	l.Info("%s - succefully end, time taken: %s", op, time.Since(now).String())
+
+	if n := certs.Expiring(); n > 0 {
+		l.Warn("%s - %d tasks have certificates expiring within %d days", op, n, cfg.Fetcher.CertWarnDays)
+		fmt.Fprintf(os.Stderr, "%d tasks have certificates expiring within %d days\n", n, cfg.Fetcher.CertWarnDays)
+
+		return ExitCertExpiring
+	}
+
+	return 0
+}
+
+func newTLSOptions(cfg config.TLS) fetcher.TLSOptions {
+	opts := fetcher.TLSOptions{
+		TLSConfig: fetcher.TLSConfig{
+			CAFiles:    cfg.CAFiles,
+			CertFile:   cfg.CertFile,
+			KeyFile:    cfg.KeyFile,
+			MinVersion: cfg.MinVersion,
+			Insecure:   cfg.InsecureSkipVerify,
+		},
+	}
+
+	for _, h := range cfg.Hosts {
+		opts.Rules = append(opts.Rules, fetcher.TLSRule{
+			Pattern:    h.Pattern,
+			CAFiles:    h.CAFiles,
+			CertFile:   h.CertFile,
+			KeyFile:    h.KeyFile,
+			MinVersion: h.MinVersion,
+			ServerName: h.ServerName,
+			Insecure:   h.InsecureSkipVerify,
+		})
+	}
+
+	return opts
+}
+
+// newAuth returns provider of the auth rules, nil when there are no rules.
+// OAuth2 tokens are requested through the proxies and tls options of the fetcher.
+func newAuth(rules []config.AuthRule, proxies []fetcher.ProxyRule, tlsOpts fetcher.TLSOptions) (auth.Provider, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	transport, err := fetcher.NewTransport(proxies, tlsOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   authTokenTimeout,
+	}
+
+	hostRules := make([]auth.Rule, 0, len(rules))
+
+	for _, r := range rules {
+		p, err := auth.NewProvider(auth.Config{
+			Type:            auth.Type(r.Type),
+			Username:        r.Username,
+			Password:        r.Password,
+			PasswordEnv:     r.PasswordEnv,
+			Token:           r.Token,
+			TokenEnv:        r.TokenEnv,
+			TokenFile:       r.TokenFile,
+			TokenURL:        r.TokenURL,
+			ClientID:        r.ClientID,
+			ClientSecret:    r.ClientSecret,
+			ClientSecretEnv: r.ClientSecretEnv,
+			Scopes:          r.Scopes,
+		}, client)
+		if err != nil {
+			return nil, fmt.Errorf("auth for %s: %w", r.Pattern, err)
+		}
+
+		hostRules = append(hostRules, auth.Rule{Pattern: r.Pattern, Provider: p})
+	}
+
+	return auth.NewHosts(hostRules)
+}
+
+// reportBreakers writes summary of the opened circuits to stderr, results go to stdout.
+func reportBreakers(stats []fetcher.BreakerStat, l logger.Interface) {
+	for _, s := range stats {
+		msg := fmt.Sprintf("circuit breaker: host %s opened %d times, %d requests failed fast, now %s",
+			s.Host, s.Opened, s.FastFailed, s.State)
+
+		l.Warn(msg)
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
+
+// newLimiter returns limiter of hosts, nil if there are no limits.
+// Hosts are paused by Retry-After for maxPause at most.
+func newLimiter(cfg config.Limits, maxPause time.Duration) (usecase.Limiter, error) {
+	if cfg.MaxInFlight == 0 && cfg.Delay == 0 && cfg.RPS == 0 && cfg.GlobalRPS == 0 && len(cfg.Hosts) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]limiter.HostRule, 0, len(cfg.Hosts))
+	for _, h := range cfg.Hosts {
+		rules = append(rules, limiter.HostRule{
+			Pattern:     h.Pattern,
+			MaxInFlight: h.MaxInFlight,
+			Delay:       h.Delay,
+			RPS:         h.RPS,
+			Burst:       h.Burst,
+		})
+	}
+
+	return limiter.NewHostLimiter(limiter.HostOptions{
+		MaxInFlight: cfg.MaxInFlight,
+		Delay:       cfg.Delay,
+		RPS:         cfg.RPS,
+		Burst:       cfg.Burst,
+		GlobalRPS:   cfg.GlobalRPS,
+		GlobalBurst: cfg.GlobalBurst,
+		MaxPause:    maxPause,
+		ByDomain:    cfg.ByDomain,
+		Rules:       rules,
+	})
 }
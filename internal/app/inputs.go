@@ -0,0 +1,97 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/filereader"
+)
+
+const (
+	stdinPath = "-"
+	stdinName = "stdin"
+)
+
+var ErrNoInput = errors.New("no input files")
+
+// openInputs expands glob patterns and opens every input, "-" means stdin.
+// Stdin is read when no paths are given and it is not a terminal.
+// Returned function closes all opened files.
+func openInputs(paths []string) ([]filereader.Source, func(), error) {
+	if len(paths) == 0 {
+		if !isPiped(os.Stdin) {
+			return nil, nil, ErrNoInput
+		}
+
+		paths = []string{stdinPath}
+	}
+
+	expanded := make([]string, 0, len(paths))
+
+	for _, p := range paths {
+		if p == stdinPath || !strings.ContainsAny(p, "*?[") {
+			expanded = append(expanded, p)
+			continue
+		}
+
+		matches, err := filepath.Glob(p)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bad pattern %s: %w", p, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, nil, fmt.Errorf("%w: nothing matches %s", ErrNoInput, p)
+		}
+
+		expanded = append(expanded, matches...)
+	}
+
+	sources := make([]filereader.Source, 0, len(expanded))
+	files := make([]*os.File, 0, len(expanded))
+
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	stdinUsed := false
+
+	for _, p := range expanded {
+		if p == stdinPath {
+			if stdinUsed {
+				continue
+			}
+
+			stdinUsed = true
+
+			sources = append(sources, filereader.Source{Name: stdinName, R: os.Stdin})
+
+			continue
+		}
+
+		fh, err := os.OpenFile(p, os.O_RDONLY, 0444)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("could't read file %s: %w", p, err)
+		}
+
+		files = append(files, fh)
+		sources = append(sources, filereader.Source{Name: p, R: fh})
+	}
+
+	return sources, closeAll, nil
+}
+
+// isPiped reports whether the file is not a terminal.
+func isPiped(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice == 0
+}
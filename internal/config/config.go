@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 	"gopkg.in/yaml.v3"
@@ -11,8 +12,27 @@ import (
 
 // Config -.
 type Config struct {
-	Log `yaml:"logger"`
-	App `yaml:"app"`
+	Log     `yaml:"logger"`
+	App     `yaml:"app"`
+	Input   `yaml:"input"`
+	Output  `yaml:"output"`
+	Fetcher `yaml:"fetcher"`
+	Retry   `yaml:"retry"`
+	Limits  `yaml:"limits"`
+	Breaker `yaml:"breaker"`
+
+	// Auth rules are checked in order, the first matching the host wins
+	Auth []AuthRule `yaml:"auth"`
+
+	// Proxies are checked in order, the first matching the host wins,
+	// environment HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used when none matches
+	Proxies []ProxyRule `yaml:"proxies"`
+
+	TLS `yaml:"tls"`
+
+	Redirects `yaml:"redirects"`
+
+	Timeouts `yaml:"timeouts"`
 }
 
 // App -.
@@ -20,6 +40,211 @@ type App struct {
 	NumberOfWorkers int `env-required:"true" yaml:"workers"`
 }
 
+// Input -.
+type Input struct {
+	Format string `yaml:"format" env:"INPUT_FORMAT" env-default:"auto"`
+}
+
+// Output -.
+type Output struct {
+	Format   string   `yaml:"format" env:"OUTPUT_FORMAT" env-default:"text"`
+	Columns  []string `yaml:"columns" env:"OUTPUT_COLUMNS" env-separator:","`
+	Template string   `yaml:"template" env:"OUTPUT_TEMPLATE"`
+
+	// Ordered output waits for at most ReorderWindow tasks to restore input order
+	Ordered       bool `yaml:"ordered" env:"OUTPUT_ORDERED"`
+	ReorderWindow int  `yaml:"reorder_window" env:"OUTPUT_REORDER_WINDOW" env-default:"1000"`
+}
+
+// Fetcher -.
+type Fetcher struct {
+	// ReadLimit is bytes of the body kept in the report
+	ReadLimit int64 `yaml:"read_limit" env:"FETCHER_READ_LIMIT" env-default:"128"`
+
+	// BodyDir to save complete bodies to, files are named by task id or hash of the request
+	BodyDir    string `yaml:"body_dir" env:"FETCHER_BODY_DIR"`
+	BodyNaming string `yaml:"body_naming" env:"FETCHER_BODY_NAMING" env-default:"id"`
+
+	// Headers "Name: value" are sent with every request, headers of the task win
+	Headers   []string `yaml:"headers" env:"FETCHER_HEADERS" env-separator:"\n"`
+	UserAgent string   `yaml:"user_agent" env:"FETCHER_USER_AGENT"`
+
+	// Cookies enables the cookie jar shared by tasks of the same host
+	Cookies bool `yaml:"cookies" env:"FETCHER_COOKIES"`
+
+	// CertWarnDays flags certificates expiring within them, zero means no check
+	CertWarnDays int `yaml:"cert_warn_days" env:"FETCHER_CERT_WARN_DAYS"`
+}
+
+// Retry -.
+type Retry struct {
+	// MaxAttempts of the task not having own max_retries
+	MaxAttempts int `yaml:"max_attempts" env:"RETRY_MAX_ATTEMPTS" env-default:"3"`
+
+	WaitMin time.Duration `yaml:"wait_min" env:"RETRY_WAIT_MIN" env-default:"50ms"`
+	WaitMax time.Duration `yaml:"wait_max" env:"RETRY_WAIT_MAX" env-default:"5s"`
+
+	// Backoff is exponential, constant, linear, full_jitter, equal_jitter or decorrelated_jitter,
+	// jitter is random unless Seed is set
+	Backoff string `yaml:"backoff" env:"RETRY_BACKOFF" env-default:"exponential"`
+	Seed    int64  `yaml:"seed" env:"RETRY_SEED"`
+
+	// MaxRetryAfter is the longest honored Retry-After wait, longer ones are
+	// truncated to it or the task is abandoned if AbandonLongRetryAfter is set
+	MaxRetryAfter         time.Duration `yaml:"max_retry_after" env:"RETRY_MAX_RETRY_AFTER" env-default:"1m"`
+	AbandonLongRetryAfter bool          `yaml:"abandon_long_retry_after" env:"RETRY_ABANDON_LONG_RETRY_AFTER"`
+
+	// Statuses are codes "503", ranges "500-504" or classes "5xx"
+	Statuses []string `yaml:"statuses" env:"RETRY_STATUSES" env-separator:"," env-default:"429,503"`
+
+	// Errors are categories of transport errors to retry on, e.g. timeout,
+	// empty means auth_error and all of them but too_many_redirects, "none" means no retries of errors
+	Errors []string `yaml:"errors" env:"RETRY_ERRORS" env-separator:","`
+
+	// NonIdempotent allows to retry POST and PATCH without Idempotency-Key header
+	NonIdempotent bool `yaml:"non_idempotent" env:"RETRY_NON_IDEMPOTENT"`
+}
+
+// Breaker -.
+type Breaker struct {
+	// Failures in a row opening the host circuit, zero means no breaker
+	Failures int `yaml:"failures" env:"BREAKER_FAILURES"`
+
+	// Cooldown of the open circuit before a probe request
+	Cooldown time.Duration `yaml:"cooldown" env:"BREAKER_COOLDOWN" env-default:"30s"`
+}
+
+// Limits -.
+type Limits struct {
+	// MaxInFlight requests and Delay between their starts per host, zero means no limit
+	MaxInFlight int           `yaml:"max_in_flight" env:"LIMITS_MAX_IN_FLIGHT"`
+	Delay       time.Duration `yaml:"delay" env:"LIMITS_DELAY"`
+
+	// RPS per host allowing Burst requests at once, zero means no limit
+	RPS   float64 `yaml:"rps" env:"LIMITS_RPS"`
+	Burst int     `yaml:"burst" env:"LIMITS_BURST"`
+
+	// GlobalRPS of all requests allowing GlobalBurst requests at once
+	GlobalRPS   float64 `yaml:"global_rps" env:"LIMITS_GLOBAL_RPS"`
+	GlobalBurst int     `yaml:"global_burst" env:"LIMITS_GLOBAL_BURST"`
+
+	// ByDomain limits registered domains (example.co.uk) instead of hosts
+	ByDomain bool `yaml:"by_domain" env:"LIMITS_BY_DOMAIN"`
+
+	// Hosts override limits for the matching hosts, the first match wins
+	Hosts []HostLimit `yaml:"hosts"`
+}
+
+// HostLimit -.
+type HostLimit struct {
+	// Pattern is a host, *.example.com for subdomains or * for any host
+	Pattern     string        `yaml:"pattern"`
+	MaxInFlight int           `yaml:"max_in_flight"`
+	Delay       time.Duration `yaml:"delay"`
+	RPS         float64       `yaml:"rps"`
+	Burst       int           `yaml:"burst"`
+}
+
+// Redirects -.
+type Redirects struct {
+	// NoFollow returns redirect responses as the result
+	NoFollow bool `yaml:"no_follow" env:"REDIRECTS_NO_FOLLOW"`
+
+	// MaxHops followed before the task fails as too_many_redirects
+	MaxHops int `yaml:"max_hops" env:"REDIRECTS_MAX_HOPS" env-default:"10"`
+
+	// SameHost follows redirects to the same host only
+	SameHost bool `yaml:"same_host" env:"REDIRECTS_SAME_HOST"`
+
+	// StripAuth drops Authorization and Cookie headers on a redirect to another host
+	StripAuth bool `yaml:"strip_auth" env:"REDIRECTS_STRIP_AUTH"`
+}
+
+// Timeouts -.
+type Timeouts struct {
+	// Total of a single attempt with the body read, zero means no limit
+	Total time.Duration `yaml:"total" env:"TIMEOUTS_TOTAL"`
+
+	// Connect including dns lookup and TLSHandshake
+	Connect      time.Duration `yaml:"connect" env:"TIMEOUTS_CONNECT" env-default:"30s"`
+	TLSHandshake time.Duration `yaml:"tls_handshake" env:"TIMEOUTS_TLS_HANDSHAKE" env-default:"10s"`
+
+	// ResponseHeader is the wait for the response after the request is written,
+	// BodyIdle is the longest pause while reading the body, zero means no limit
+	ResponseHeader time.Duration `yaml:"response_header" env:"TIMEOUTS_RESPONSE_HEADER"`
+	BodyIdle       time.Duration `yaml:"body_idle" env:"TIMEOUTS_BODY_IDLE"`
+}
+
+// TLS -.
+type TLS struct {
+	// CAFiles are PEM bundles trusted in addition to the system roots
+	CAFiles []string `yaml:"ca_files" env:"TLS_CA_FILES" env-separator:","`
+
+	// CertFile and KeyFile are client certificate for mTLS
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+
+	// MinVersion is 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `yaml:"min_version" env:"TLS_MIN_VERSION"`
+
+	// InsecureSkipVerify of server certificates, for tests only
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify" env:"TLS_INSECURE_SKIP_VERIFY"`
+
+	// Hosts override the settings for the matching hosts, the first match wins
+	Hosts []TLSHost `yaml:"hosts"`
+}
+
+// TLSHost -.
+type TLSHost struct {
+	// Pattern is a host, *.example.com for subdomains or * for any host
+	Pattern string `yaml:"pattern"`
+
+	// CAFiles are added to the common ones
+	CAFiles    []string `yaml:"ca_files"`
+	CertFile   string   `yaml:"cert_file"`
+	KeyFile    string   `yaml:"key_file"`
+	MinVersion string   `yaml:"min_version"`
+
+	// ServerName overrides SNI and the name the certificate is verified against
+	ServerName string `yaml:"server_name"`
+
+	// InsecureSkipVerify overrides the common setting when set, false enforces verification
+	InsecureSkipVerify *bool `yaml:"insecure_skip_verify"`
+}
+
+// ProxyRule -.
+type ProxyRule struct {
+	// Pattern is a host, *.example.com for subdomains or * for any host
+	Pattern string `yaml:"pattern"`
+
+	// URL is http://, https:// or socks5:// with optional user:password, or direct
+	URL string `yaml:"url"`
+}
+
+// AuthRule -.
+type AuthRule struct {
+	// Pattern is a host, *.example.com for subdomains or * for any host
+	Pattern string `yaml:"pattern"`
+
+	// Type is basic, bearer or client_credentials
+	Type string `yaml:"type"`
+
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+
+	// Token is taken from TokenEnv variable or TokenFile when empty
+	Token     string `yaml:"token"`
+	TokenEnv  string `yaml:"token_env"`
+	TokenFile string `yaml:"token_file"`
+
+	TokenURL        string   `yaml:"token_url"`
+	ClientID        string   `yaml:"client_id"`
+	ClientSecret    string   `yaml:"client_secret"`
+	ClientSecretEnv string   `yaml:"client_secret_env"`
+	Scopes          []string `yaml:"scopes"`
+}
+
 // Log -.
 type Log struct {
 	Level string `env-required:"true" yaml:"level" env:"LOG_LEVEL"`
@@ -65,6 +290,35 @@ func Prepare() error {
 			Level: "debug",
 			Path:  "log.log",
 		},
+		Input: Input{
+			Format: "auto",
+		},
+		Output: Output{
+			Format:        "text",
+			ReorderWindow: 1000,
+		},
+		Fetcher: Fetcher{
+			ReadLimit:  128,
+			BodyNaming: "id",
+		},
+		Retry: Retry{
+			MaxAttempts:   3,
+			WaitMin:       50 * time.Millisecond,
+			WaitMax:       5 * time.Second,
+			Backoff:       "exponential",
+			Statuses:      []string{"429", "503"},
+			MaxRetryAfter: time.Minute,
+		},
+		Breaker: Breaker{
+			Cooldown: 30 * time.Second,
+		},
+		Redirects: Redirects{
+			MaxHops: 10,
+		},
+		Timeouts: Timeouts{
+			Connect:      30 * time.Second,
+			TLSHandshake: 10 * time.Second,
+		},
 	}
 
 	yamlData, err := yaml.Marshal(&cfg)
@@ -1,6 +1,9 @@
 package entity
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 type StateStatus int
 
@@ -11,14 +14,128 @@ const (
 	StateStatusError
 )
 
+// ErrorCategory is a kind of the task failure, empty on success.
+type ErrorCategory string
+
+const (
+	ErrorCategoryDNS               ErrorCategory = "dns_failure"
+	ErrorCategoryConnectionRefused ErrorCategory = "connection_refused"
+	ErrorCategoryTLS               ErrorCategory = "tls_error"
+	ErrorCategoryTimeout           ErrorCategory = "timeout"
+	ErrorCategoryTooManyRedirects  ErrorCategory = "too_many_redirects"
+	ErrorCategoryContextCanceled   ErrorCategory = "context_canceled"
+	ErrorCategoryRetriesExhausted  ErrorCategory = "retries_exhausted"
+	ErrorCategoryRetryAfterTooLong ErrorCategory = "retry_after_too_long"
+	ErrorCategoryCircuitOpen       ErrorCategory = "circuit_open"
+	ErrorCategoryAuth              ErrorCategory = "auth_error"
+	ErrorCategoryHTTP              ErrorCategory = "http_error"
+	ErrorCategoryInvalidRequest    ErrorCategory = "invalid_request"
+	ErrorCategoryOther             ErrorCategory = "other"
+)
+
 type State struct {
 	Retries    int
 	MaxRetries int
 	Status     StateStatus
+
+	// NotBefore is the earliest time of the next attempt, zero means now
+	NotBefore time.Time
 }
 
 type InputParams struct {
-	URL string
+	URL     string
+	Method  string
+	Headers http.Header
+	Body    string
+	Tags    []string
+
+	// Timeout of the whole attempt and Timeouts of its phases, zero means run default
+	Timeout  time.Duration
+	Timeouts Timeouts
+
+	// ReadLimit of the captured content, zero means run default
+	ReadLimit int64
+
+	// ExpectedStatus is zero when any status is acceptable
+	ExpectedStatus int
+}
+
+// Timeouts of the phases of a single http attempt, zero means no limit.
+type Timeouts struct {
+	// Connect includes dns lookup
+	Connect      time.Duration
+	TLSHandshake time.Duration
+
+	// ResponseHeader is the wait for the response after the request is written
+	ResponseHeader time.Duration
+
+	// BodyIdle is the longest wait for the next chunk of the body
+	BodyIdle time.Duration
+}
+
+// Timing of the phases of a single http attempt, zero when the phase did not happen.
+type Timing struct {
+	DNSLookup    time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+
+	// TimeToFirstByte is the time from the request written to the first byte of response
+	TimeToFirstByte time.Duration
+	ContentTransfer time.Duration
+	Total           time.Duration
+
+	// ConnReused is set when a kept-alive connection was used, so there is no dns and connect
+	ConnReused bool
+}
+
+// Attempt is a single try of the request.
+type Attempt struct {
+	Number        int
+	Started       time.Time
+	StatusCode    int
+	Error         string
+	ErrorCategory ErrorCategory
+
+	// Backoff is the wait chosen before the next attempt, zero for the last one
+	Backoff time.Duration
+	Timing  Timing
+
+	// RetryAfter is the wait requested by the server, WaitTruncated is set
+	// when it was longer than allowed and Backoff was cut to the limit,
+	// Abandoned when the task was given up instead
+	RetryAfter    time.Duration
+	WaitTruncated bool
+	Abandoned     bool
+}
+
+// Redirect is a response to URL with StatusCode redirecting to Location.
+type Redirect struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+// Certificate of the peer chain.
+type Certificate struct {
+	Subject  string
+	Issuer   string
+	SANs     []string
+	NotAfter time.Time
+
+	// DaysLeft till NotAfter, negative when expired
+	DaysLeft int
+}
+
+// TLSInfo of the connection, empty Version means no tls.
+type TLSInfo struct {
+	Version     string
+	CipherSuite string
+
+	// Chain starts with the leaf certificate
+	Chain []Certificate
+
+	// ExpiresSoon is set when a certificate of the chain is within the warning threshold
+	ExpiresSoon bool
 }
 
 type OutputParams struct {
@@ -27,21 +144,52 @@ type OutputParams struct {
 	TimeStarted   time.Time
 	TimeCompleted time.Time
 	ContentLength int64
+
+	// Error is the final error of the task with its category, empty on success
+	Error         string
+	ErrorCategory ErrorCategory
+
+	// Complete body is saved to BodyPath when body saving is enabled
+	BodyPath   string
+	BodySize   int64
+	BodySHA256 string
+
+	// Timing of the last attempt
+	Timing Timing
+
+	// Proxy of the last attempt without password, empty for direct connection
+	Proxy string
+
+	// TLS of the last attempt
+	TLS TLSInfo
+
+	// Redirects of the last attempt in order, FinalURL is the url of the final response
+	Redirects []Redirect
+	FinalURL  string
+
+	// Attempts history in order, the last one is the final
+	Attempts []Attempt
 }
 
 type Task struct {
-	ID           string
+	ID string
+
+	// Seq is the position of the task in input starting from 1, zero if unknown
+	Seq int
+
 	InputParams  InputParams
 	OutputParams OutputParams
 	CurrentState State
 }
 
 func Constructor(id, url string, maxRetries int) Task {
+	return ConstructorWithParams(id, InputParams{URL: url}, maxRetries)
+}
+
+func ConstructorWithParams(id string, params InputParams, maxRetries int) Task {
 	return Task{
-		ID: id,
-		InputParams: InputParams{
-			URL: url,
-		},
+		ID:           id,
+		InputParams:  params,
 		OutputParams: OutputParams{},
 		CurrentState: State{
 			Status:     StateStatusInitial,
@@ -62,3 +210,17 @@ func (t Task) IsReady() bool {
 
 	return true
 }
+
+// Duration returns time taken by the task including all retries.
+func (t Task) Duration() time.Duration {
+	if t.OutputParams.TimeStarted.IsZero() || t.OutputParams.TimeCompleted.IsZero() {
+		return 0
+	}
+
+	return t.OutputParams.TimeCompleted.Sub(t.OutputParams.TimeStarted)
+}
+
+// IsExpected reports whether the received status code matches the expected one.
+func (t Task) IsExpected() bool {
+	return t.InputParams.ExpectedStatus == 0 || t.InputParams.ExpectedStatus == t.OutputParams.StatusCode
+}
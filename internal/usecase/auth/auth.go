@@ -0,0 +1,179 @@
+// Package auth attaches credentials to requests of the hosts configured.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/antonmisa/cliurlfetcher/pkg/hostmatch"
+)
+
+// Type of the provider.
+type Type string
+
+const (
+	TypeBasic             Type = "basic"
+	TypeBearer            Type = "bearer"
+	TypeClientCredentials Type = "client_credentials"
+)
+
+var (
+	ErrAuth        = errors.New("auth failed")
+	ErrBadProvider = errors.New("bad auth provider")
+)
+
+// Provider sets credentials of the request.
+type Provider interface {
+	Authorize(ctx context.Context, req *http.Request) error
+}
+
+// Config of a provider, secrets are taken from env or file when the value is empty.
+type Config struct {
+	Type Type
+
+	// Username and Password of basic auth
+	Username    string
+	Password    string
+	PasswordEnv string
+
+	// Token of bearer auth
+	Token     string
+	TokenEnv  string
+	TokenFile string
+
+	// TokenURL, ClientID and ClientSecret of OAuth2 client credentials grant
+	TokenURL        string
+	ClientID        string
+	ClientSecret    string
+	ClientSecretEnv string
+	Scopes          []string
+}
+
+// NewProvider returns provider of the config type, client is used to get OAuth2 tokens.
+func NewProvider(cfg Config, client *http.Client) (Provider, error) {
+	switch cfg.Type {
+	case TypeBasic:
+		password, err := secret(cfg.Password, cfg.PasswordEnv, "")
+		if err != nil {
+			return nil, err
+		}
+
+		return Basic{Username: cfg.Username, Password: password}, nil
+	case TypeBearer:
+		token, err := secret(cfg.Token, cfg.TokenEnv, cfg.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if token == "" {
+			return nil, fmt.Errorf("%w: empty bearer token", ErrBadProvider)
+		}
+
+		return Bearer{Token: token}, nil
+	case TypeClientCredentials:
+		clientSecret, err := secret(cfg.ClientSecret, cfg.ClientSecretEnv, "")
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.TokenURL == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("%w: token url and client id are required", ErrBadProvider)
+		}
+
+		return NewClientCredentials(cfg.TokenURL, cfg.ClientID, clientSecret, cfg.Scopes, client), nil
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrBadProvider, cfg.Type)
+	}
+}
+
+// Rule selects provider of the hosts matching the pattern, see hostmatch.Match.
+type Rule struct {
+	Pattern  string
+	Provider Provider
+}
+
+// Hosts authorizes requests by the first rule matching the host.
+type Hosts struct {
+	rules []Rule
+}
+
+var _ Provider = (*Hosts)(nil)
+
+func NewHosts(rules []Rule) (*Hosts, error) {
+	for _, r := range rules {
+		if err := hostmatch.Validate(r.Pattern); err != nil {
+			return nil, err
+		}
+
+		if r.Provider == nil {
+			return nil, fmt.Errorf("%w: no provider for %s", ErrBadProvider, r.Pattern)
+		}
+	}
+
+	return &Hosts{rules: rules}, nil
+}
+
+// Authorize sets credentials of the first matching rule, the request having
+// own Authorization header is kept as is.
+func (h *Hosts) Authorize(ctx context.Context, req *http.Request) error {
+	if req.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	for _, r := range h.rules {
+		if hostmatch.Match(r.Pattern, req.URL.Hostname()) {
+			return r.Provider.Authorize(ctx, req)
+		}
+	}
+
+	return nil
+}
+
+// Basic auth.
+type Basic struct {
+	Username string
+	Password string
+}
+
+func (b Basic) Authorize(_ context.Context, req *http.Request) error {
+	req.SetBasicAuth(b.Username, b.Password)
+	return nil
+}
+
+// Bearer is a static token.
+type Bearer struct {
+	Token string
+}
+
+func (b Bearer) Authorize(_ context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+	return nil
+}
+
+// secret returns the value, or the env variable, or the file content trimmed.
+func secret(value, env, path string) (string, error) {
+	switch {
+	case value != "":
+		return value, nil
+	case env != "":
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("%w: env %s is not set", ErrBadProvider, env)
+		}
+
+		return v, nil
+	case path != "":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrBadProvider, err)
+		}
+
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", nil
+	}
+}
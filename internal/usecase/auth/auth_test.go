@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tokenServer issues tokens numbered by request, expiresIn is their lifetime.
+func tokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var issued atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		id, secret, ok := req.BasicAuth()
+		if !ok || id != "client" || secret != "s3cret" || req.FormValue("grant_type") != "client_credentials" {
+			res.WriteHeader(http.StatusUnauthorized)
+			res.Write([]byte(`{"error":"invalid_client"}`))
+
+			return
+		}
+
+		n := issued.Add(1)
+
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d-%s", n, req.FormValue("scope")),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &issued
+}
+
+func authorization(t *testing.T, p Provider, rawURL string) string {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, rawURL, nil)
+	require.NoError(t, p.Authorize(context.Background(), req))
+
+	return req.Header.Get("Authorization")
+}
+
+func TestClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	srv, issued := tokenServer(t, 3600)
+
+	cc := NewClientCredentials(srv.URL, "client", "s3cret", []string{"read", "write"}, srv.Client())
+
+	// cached token is shared by concurrent requests
+	var wg sync.WaitGroup
+
+	got := make([]string, 10)
+	for i := range got {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil)
+			if err := cc.Authorize(context.Background(), req); err == nil {
+				got[i] = req.Header.Get("Authorization")
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, h := range got {
+		require.Equal(t, "Bearer token-1-read write", h)
+	}
+
+	require.Equal(t, int32(1), issued.Load())
+
+	_, err := NewClientCredentials(srv.URL, "client", "wrong", nil, srv.Client()).Token(context.Background())
+	require.ErrorIs(t, err, ErrAuth)
+	require.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestClientCredentials_Refresh(t *testing.T) {
+	t.Parallel()
+
+	// the token without expires_in lives the default lifetime
+	srv, issued := tokenServer(t, 0)
+
+	cc := NewClientCredentials(srv.URL, "client", "s3cret", nil, srv.Client())
+
+	token, err := cc.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-1-", token)
+
+	// about to expire
+	cc.refreshAt = cc.refreshAt.Add(-defaultTokenLifetime)
+
+	token, err = cc.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-2-", token)
+	require.Equal(t, int32(2), issued.Load())
+}
+
+func TestNewProvider(t *testing.T) {
+	t.Setenv("AUTH_TEST_TOKEN", "from-env")
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("from-file\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr error
+	}{
+		{
+			name: "basic",
+			cfg:  Config{Type: TypeBasic, Username: "user", Password: "pass"},
+			want: "Basic dXNlcjpwYXNz",
+		},
+		{
+			name: "bearer env",
+			cfg:  Config{Type: TypeBearer, TokenEnv: "AUTH_TEST_TOKEN"},
+			want: "Bearer from-env",
+		},
+		{
+			name: "bearer file",
+			cfg:  Config{Type: TypeBearer, TokenFile: tokenFile},
+			want: "Bearer from-file",
+		},
+		{
+			name:    "env not set",
+			cfg:     Config{Type: TypeBearer, TokenEnv: "AUTH_TEST_NOT_SET"},
+			wantErr: ErrBadProvider,
+		},
+		{
+			name:    "client credentials without url",
+			cfg:     Config{Type: TypeClientCredentials, ClientID: "client"},
+			wantErr: ErrBadProvider,
+		},
+		{
+			name:    "unknown",
+			cfg:     Config{Type: "digest"},
+			wantErr: ErrBadProvider,
+		},
+	}
+
+	for _, tc := range tests {
+		p, err := NewProvider(tc.cfg, nil)
+		if tc.wantErr != nil {
+			require.ErrorIs(t, err, tc.wantErr, tc.name)
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.want, authorization(t, p, "http://example.com/"), tc.name)
+	}
+}
+
+func TestHosts(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := tokenServer(t, 3600)
+
+	h, err := NewHosts([]Rule{
+		{Pattern: "api.example.com", Provider: Basic{Username: "u", Password: "p"}},
+		{Pattern: "*.example.com", Provider: NewClientCredentials(srv.URL, "client", "s3cret", nil, srv.Client())},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "Basic dTpw", authorization(t, h, "https://API.example.com/v1"))
+	require.Equal(t, "Bearer token-1-", authorization(t, h, "https://www.example.com/"))
+	require.Empty(t, authorization(t, h, "https://example.org/"))
+
+	// own header of the request is kept
+	req := httptest.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	req.Header.Set("Authorization", "Token own")
+	require.NoError(t, h.Authorize(context.Background(), req))
+	require.Equal(t, "Token own", req.Header.Get("Authorization"))
+
+	_, err = NewHosts([]Rule{{Pattern: "a.*", Provider: Bearer{Token: "t"}}})
+	require.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// refreshBefore expiry, tokens living less are refreshed at the half of their life
+	refreshBefore = 30 * time.Second
+
+	// defaultTokenLifetime when the token endpoint does not tell it
+	defaultTokenLifetime = time.Hour
+
+	tokenReadLimit = 1 << 20
+)
+
+// ClientCredentials gets tokens by OAuth2 client credentials grant,
+// the token is cached and refreshed before expiry.
+type ClientCredentials struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes []string, client *http.Client) *ClientCredentials {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &ClientCredentials{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		client:       client,
+	}
+}
+
+func (c *ClientCredentials) Authorize(ctx context.Context, req *http.Request) error {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// Token returns the cached token, a new one is requested when it is about to expire.
+// Concurrent callers wait for the single request.
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if c.token != "" && now.Before(c.refreshAt) {
+		return c.token, nil
+	}
+
+	tr, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	lifetime := defaultTokenLifetime
+	if tr.ExpiresIn > 0 {
+		lifetime = time.Duration(tr.ExpiresIn) * time.Second
+	}
+
+	before := refreshBefore
+	if lifetime < 2*refreshBefore {
+		before = lifetime / 2
+	}
+
+	c.token = tr.AccessToken
+	c.refreshAt = now.Add(lifetime - before)
+
+	return c.token, nil
+}
+
+func (c *ClientCredentials) fetch(ctx context.Context) (tokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("%w: token request: %w", ErrAuth, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, tokenReadLimit))
+	if err != nil {
+		return tokenResponse{}, fmt.Errorf("%w: token response: %w", ErrAuth, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return tokenResponse{}, fmt.Errorf("%w: token endpoint answered %d: %s",
+			ErrAuth, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return tokenResponse{}, fmt.Errorf("%w: token response: %w", ErrAuth, err)
+	}
+
+	if tr.AccessToken == "" {
+		return tokenResponse{}, fmt.Errorf("%w: no access token in response", ErrAuth)
+	}
+
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return tokenResponse{}, fmt.Errorf("%w: unsupported token type %s", ErrAuth, tr.TokenType)
+	}
+
+	return tr, nil
+}
@@ -0,0 +1,167 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackoffStrategy is a name of the Backoff implementation.
+type BackoffStrategy string
+
+const (
+	BackoffExponential  BackoffStrategy = "exponential"
+	BackoffConstant     BackoffStrategy = "constant"
+	BackoffLinear       BackoffStrategy = "linear"
+	BackoffFullJitter   BackoffStrategy = "full_jitter"
+	BackoffEqualJitter  BackoffStrategy = "equal_jitter"
+	BackoffDecorrelated BackoffStrategy = "decorrelated_jitter"
+)
+
+var ErrUnknownBackoff = errors.New("unknown backoff strategy")
+
+// ParseBackoffStrategy returns the strategy by its name, empty name means BackoffExponential.
+func ParseBackoffStrategy(s string) (BackoffStrategy, error) {
+	switch b := BackoffStrategy(strings.ToLower(strings.TrimSpace(s))); b {
+	case "":
+		return BackoffExponential, nil
+	case BackoffExponential, BackoffConstant, BackoffLinear, BackoffFullJitter, BackoffEqualJitter, BackoffDecorrelated:
+		return b, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownBackoff, s)
+	}
+}
+
+// NewBackoff returns Backoff of the strategy. Jittered strategies use random
+// source of the seed, so the same seed gives the same waits, zero seed means
+// a random one. Every strategy respects Retry-After header as DefaultBackoff does.
+func NewBackoff(strategy BackoffStrategy, seed int64) Backoff {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rnd := &lockedRand{r: rand.New(rand.NewSource(seed))} //nolint:gosec // jitter is not a secret
+
+	var wait Backoff
+
+	switch strategy {
+	case BackoffConstant:
+		wait = func(min, _ time.Duration, _ int, _ time.Duration, _ *http.Response) time.Duration {
+			return min
+		}
+	case BackoffLinear:
+		wait = func(min, max time.Duration, attemptNum int, _ time.Duration, _ *http.Response) time.Duration {
+			if sleep := min * time.Duration(attemptNum); sleep/time.Duration(attemptNum) == min && sleep <= max {
+				return sleep
+			}
+
+			return max
+		}
+	case BackoffFullJitter:
+		// random wait up to the exponential one
+		wait = func(min, max time.Duration, attemptNum int, _ time.Duration, _ *http.Response) time.Duration {
+			return rnd.between(0, exponential(min, max, attemptNum))
+		}
+	case BackoffEqualJitter:
+		// half of the exponential wait is kept, another half is random
+		wait = func(min, max time.Duration, attemptNum int, _ time.Duration, _ *http.Response) time.Duration {
+			half := exponential(min, max, attemptNum) / 2
+
+			return half + rnd.between(0, half)
+		}
+	case BackoffDecorrelated:
+		// random wait between min and three times of the previous one
+		wait = func(min, max time.Duration, _ int, prev time.Duration, _ *http.Response) time.Duration {
+			if prev < min {
+				prev = min
+			}
+
+			upper := prev * 3
+			if upper/3 != prev || upper > max {
+				upper = max
+			}
+
+			return rnd.between(min, upper)
+		}
+	default:
+		return DefaultBackoff
+	}
+
+	return func(min, max time.Duration, attemptNum int, prev time.Duration, resp *http.Response) time.Duration {
+		if sleep, ok := retryAfter(resp); ok {
+			return sleep
+		}
+
+		return wait(min, max, attemptNum, prev, resp)
+	}
+}
+
+// exponential returns min * 2^attemptNum limited by max.
+func exponential(min, max time.Duration, attemptNum int) time.Duration {
+	mult := math.Pow(2, float64(attemptNum)) * float64(min)
+	sleep := time.Duration(mult)
+	if float64(sleep) != mult || sleep > max {
+		sleep = max
+	}
+	return sleep
+}
+
+// retryAfter returns the wait requested by the server with 429 or 503 response.
+// Retry-After is either delay in seconds or HTTP-date, RFC 9110 section 10.2.3.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+
+	s := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if s == "" {
+		return 0, false
+	}
+
+	if sleep, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if sleep < 0 || sleep > math.MaxInt64/int64(time.Second) {
+			return 0, false
+		}
+
+		return time.Second * time.Duration(sleep), true
+	}
+
+	if date, err := http.ParseTime(s); err == nil {
+		// the date in the past means no wait
+		if sleep := time.Until(date); sleep > 0 {
+			return sleep, true
+		}
+
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// lockedRand is rand.Rand safe for concurrent use by workers.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// between returns random duration in [from, to).
+func (l *lockedRand) between(from, to time.Duration) time.Duration {
+	if to <= from {
+		return from
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return from + time.Duration(l.r.Int63n(int64(to-from)))
+}
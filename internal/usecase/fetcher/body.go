@@ -0,0 +1,185 @@
+package fetcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BodyNaming defines how saved body files are named.
+type BodyNaming string
+
+const (
+	// BodyNamingID names files by the request id, ids with characters
+	// other than letters, digits, - and . get a hash of the id appended
+	BodyNamingID BodyNaming = "id"
+	// BodyNamingHash names files by sha256 of the method, url and id,
+	// so different requests to the same url are kept apart
+	BodyNamingHash BodyNaming = "hash"
+
+	bodyFileExt = ".body"
+
+	// idHashSize is bytes of sha256 of the id added to the file name
+	idHashSize = 8
+)
+
+var ErrUnknownBodyNaming = errors.New("unknown body naming")
+
+// ParseBodyNaming returns naming by its name, empty name means BodyNamingID.
+func ParseBodyNaming(s string) (BodyNaming, error) {
+	switch n := BodyNaming(strings.ToLower(s)); n {
+	case "":
+		return BodyNamingID, nil
+	case BodyNamingID, BodyNamingHash:
+		return n, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownBodyNaming, s)
+	}
+}
+
+// bodyInfo describes the body saved to file.
+type bodyInfo struct {
+	path   string
+	size   int64
+	sha256 string
+}
+
+// readLimit returns limit of the content kept in response.
+func (f *Fetcher) readLimit(req FetcherRequest) int64 {
+	if req.ReadLimit > 0 {
+		return req.ReadLimit
+	}
+
+	return f.opts.ReadLimit
+}
+
+// bodyPath returns the file name the request body is saved to.
+func (f *Fetcher) bodyPath(req FetcherRequest) string {
+	var name string
+
+	switch f.opts.BodyNaming {
+	case BodyNamingHash:
+		method := req.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+
+		// url and method have no new lines, so the key is never the same for distinct requests
+		sum := sha256.Sum256([]byte(method + "\n" + req.URL + "\n" + req.ID))
+		name = hex.EncodeToString(sum[:])
+	default:
+		name = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+				return r
+			}
+
+			return '_'
+		}, req.ID)
+
+		// distinct ids could be replaced to the same name, a/b:1 and a_b_1,
+		// so the name of a replaced one gets the hash of the id after _
+		if strings.ContainsRune(name, '_') {
+			sum := sha256.Sum256([]byte(req.ID))
+			name += "_" + hex.EncodeToString(sum[:idHashSize])
+		}
+	}
+
+	return filepath.Join(f.opts.BodyDir, name+bodyFileExt)
+}
+
+// Try to read the response body so we can reuse this connection. Up to the
+// read limit is returned as content, when save is set and saving is enabled
+// the whole body is streamed to the file.
+func (f *Fetcher) drainBody(req FetcherRequest, body io.ReadCloser, save bool) (string, bodyInfo, error) {
+	defer body.Close()
+
+	op := "fetcher - drainBody"
+
+	writer := bytes.NewBufferString("")
+
+	if !save || f.opts.BodyDir == "" {
+		_, err := io.Copy(writer, io.LimitReader(body, f.readLimit(req)))
+		if err != nil {
+			f.logger.Error("%s - io.Copy: %w", op, err)
+			return "", bodyInfo{}, err
+		}
+
+		return writer.String(), bodyInfo{}, err
+	}
+
+	info, err := f.saveBody(req, io.TeeReader(body, &limitedWriter{w: writer, n: f.readLimit(req)}))
+	if err != nil {
+		f.logger.Error("%s - f.saveBody: %w", op, err)
+		return writer.String(), bodyInfo{}, err
+	}
+
+	return writer.String(), info, nil
+}
+
+// saveBody writes the whole body to temporary file and renames it when done,
+// so there are no partial files under the final name.
+func (f *Fetcher) saveBody(req FetcherRequest, r io.Reader) (bodyInfo, error) {
+	path := f.bodyPath(req)
+
+	tmp, err := os.CreateTemp(f.opts.BodyDir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return bodyInfo{}, err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		tmp.Close()
+		return bodyInfo{}, err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return bodyInfo{}, err
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return bodyInfo{}, err
+	}
+
+	return bodyInfo{
+		path:   path,
+		size:   size,
+		sha256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// limitedWriter keeps first n bytes and discards the rest without an error.
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if lw.n <= 0 {
+		return len(p), nil
+	}
+
+	chunk := p
+	if int64(len(chunk)) > lw.n {
+		chunk = chunk[:lw.n]
+	}
+
+	n, err := lw.w.Write(chunk)
+	lw.n -= int64(n)
+
+	if err != nil {
+		return n, err
+	}
+
+	return len(p), nil
+}
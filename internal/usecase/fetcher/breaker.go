@@ -0,0 +1,206 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/pkg/logger"
+)
+
+const defaultBreakerCooldown = 30 * time.Second
+
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerState of the host circuit.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerOptions of the per host circuit breaker, zero Failures disables it.
+type BreakerOptions struct {
+	// Failures in a row opening the circuit
+	Failures int
+
+	// Cooldown of the open circuit before the probe, zero means default
+	Cooldown time.Duration
+}
+
+// BreakerStat is a summary of the host circuit.
+type BreakerStat struct {
+	Host       string
+	State      BreakerState
+	Opened     int
+	FastFailed int
+}
+
+// breaker opens the circuit of the host after failures in a row, requests to the host
+// fail fast then. After cooldown a single probe is let through, its success closes the circuit.
+type breaker struct {
+	mu       sync.Mutex
+	opts     BreakerOptions
+	logger   logger.Interface
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	opened     int
+	fastFailed int
+}
+
+func newBreaker(opts BreakerOptions, l logger.Interface) (*breaker, error) {
+	if opts.Failures < 0 || opts.Cooldown < 0 {
+		return nil, fmt.Errorf("negative breaker options %d, %s", opts.Failures, opts.Cooldown)
+	}
+
+	if opts.Failures == 0 {
+		return nil, nil
+	}
+
+	if opts.Cooldown == 0 {
+		opts.Cooldown = defaultBreakerCooldown
+	}
+
+	return &breaker{
+		opts:     opts,
+		logger:   l,
+		circuits: make(map[string]*circuit),
+	}, nil
+}
+
+// allow reports whether a request to the host could be made now.
+func (b *breaker) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+
+	switch c.state {
+	case BreakerOpen:
+		if time.Since(c.openedAt) < b.opts.Cooldown {
+			c.fastFailed++
+			return false
+		}
+
+		b.transit(host, c, BreakerHalfOpen)
+
+		c.probing = true
+
+		return true
+	case BreakerHalfOpen:
+		if c.probing {
+			c.fastFailed++
+			return false
+		}
+
+		c.probing = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+// record the outcome of the request allowed before.
+func (b *breaker) record(host string, category entity.ErrorCategory, statusCode int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(host)
+
+	// the host is not to blame
+	if category == entity.ErrorCategoryContextCanceled || category == entity.ErrorCategoryInvalidRequest {
+		c.probing = false
+		return
+	}
+
+	failed := statusCode >= http.StatusInternalServerError ||
+		(category != "" && category != entity.ErrorCategoryHTTP)
+
+	if !failed {
+		c.failures = 0
+
+		if c.state != BreakerClosed {
+			b.transit(host, c, BreakerClosed)
+		}
+
+		c.probing = false
+
+		return
+	}
+
+	c.failures++
+
+	if c.state == BreakerHalfOpen || c.failures >= b.opts.Failures {
+		if c.state != BreakerOpen {
+			b.transit(host, c, BreakerOpen)
+		}
+
+		c.openedAt = time.Now()
+		c.probing = false
+	}
+}
+
+// stats returns summary of the hosts ever opened, sorted by host.
+func (b *breaker) stats() []BreakerStat {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	res := make([]BreakerStat, 0)
+
+	for host, c := range b.circuits {
+		if c.opened == 0 {
+			continue
+		}
+
+		res = append(res, BreakerStat{
+			Host:       host,
+			State:      c.state,
+			Opened:     c.opened,
+			FastFailed: c.fastFailed,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Host < res[j].Host })
+
+	return res
+}
+
+func (b *breaker) circuit(host string) *circuit {
+	c, ok := b.circuits[host]
+	if !ok {
+		c = &circuit{state: BreakerClosed}
+		b.circuits[host] = c
+	}
+
+	return c
+}
+
+func (b *breaker) transit(host string, c *circuit, to BreakerState) {
+	b.logger.Warn("fetcher - breaker - host %s circuit %s -> %s after %d failures", host, c.state, to, c.failures)
+
+	if to == BreakerOpen {
+		c.opened++
+	}
+
+	c.state = to
+}
+
+// breakerKey returns host of the request url in lower case.
+func breakerKey(req *http.Request) string {
+	return strings.ToLower(req.URL.Hostname())
+}
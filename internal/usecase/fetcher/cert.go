@@ -0,0 +1,59 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+const day = 24 * time.Hour
+
+// tlsInfo describes the connection and its peer chain, warnDays flags the chain
+// having a certificate expiring within them, zero disables the check.
+func tlsInfo(cs *tls.ConnectionState, now time.Time, warnDays int) entity.TLSInfo {
+	if cs == nil {
+		return entity.TLSInfo{}
+	}
+
+	info := entity.TLSInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		Chain:       make([]entity.Certificate, 0, len(cs.PeerCertificates)),
+	}
+
+	for _, c := range cs.PeerCertificates {
+		sans := make([]string, 0, len(c.DNSNames)+len(c.IPAddresses))
+		sans = append(sans, c.DNSNames...)
+
+		for _, ip := range c.IPAddresses {
+			sans = append(sans, ip.String())
+		}
+
+		left := daysLeft(c.NotAfter, now)
+
+		info.Chain = append(info.Chain, entity.Certificate{
+			Subject:  c.Subject.String(),
+			Issuer:   c.Issuer.String(),
+			SANs:     sans,
+			NotAfter: c.NotAfter,
+			DaysLeft: left,
+		})
+
+		if warnDays > 0 && left < warnDays {
+			info.ExpiresSoon = true
+		}
+	}
+
+	return info
+}
+
+// daysLeft are whole days till notAfter, rounded down.
+func daysLeft(notAfter, now time.Time) int {
+	d := notAfter.Sub(now)
+	if d < 0 {
+		return -int((-d + day - 1) / day)
+	}
+
+	return int(d / day)
+}
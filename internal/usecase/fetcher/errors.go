@@ -0,0 +1,115 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/auth"
+)
+
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// Classify returns category of the request outcome, empty category means success.
+func Classify(err error, resp *http.Response) entity.ErrorCategory {
+	if err == nil {
+		if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+			return entity.ErrorCategoryHTTP
+		}
+
+		return ""
+	}
+
+	var (
+		dnsErr    *net.DNSError
+		netErr    net.Error
+		verifyErr *tls.CertificateVerificationError
+		alertErr  tls.AlertError
+		recordErr tls.RecordHeaderError
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		certErr   x509.CertificateInvalidError
+	)
+
+	switch {
+	case errors.Is(err, ErrNoMoreAttempts):
+		return entity.ErrorCategoryRetriesExhausted
+	case errors.Is(err, ErrRetryAfterTooLong):
+		return entity.ErrorCategoryRetryAfterTooLong
+	case errors.Is(err, auth.ErrAuth):
+		return entity.ErrorCategoryAuth
+	case errors.Is(err, ErrCircuitOpen):
+		return entity.ErrorCategoryCircuitOpen
+	case errors.Is(err, ErrTooManyRedirects):
+		return entity.ErrorCategoryTooManyRedirects
+	case errors.Is(err, ErrTimeout):
+		// the attempt is canceled on timeout of a phase
+		return entity.ErrorCategoryTimeout
+	case errors.Is(err, context.Canceled):
+		return entity.ErrorCategoryContextCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return entity.ErrorCategoryTimeout
+	case errors.As(err, &dnsErr):
+		return entity.ErrorCategoryDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return entity.ErrorCategoryConnectionRefused
+	case errors.As(err, &verifyErr), errors.As(err, &alertErr), errors.As(err, &recordErr),
+		errors.As(err, &authErr), errors.As(err, &hostErr), errors.As(err, &certErr):
+		return entity.ErrorCategoryTLS
+	case strings.Contains(err.Error(), "tls: "):
+		// handshake failures are not typed
+		return entity.ErrorCategoryTLS
+	default:
+		return entity.ErrorCategoryOther
+	}
+}
+
+// describe returns category and message of the final outcome.
+func describe(resp FetcherResponse, err error) (entity.ErrorCategory, string) {
+	if err != nil {
+		category := Classify(err, nil)
+
+		msg := errorText(err, category)
+
+		// tell why the last attempt failed
+		if category == entity.ErrorCategoryRetriesExhausted && len(resp.Attempts) > 0 {
+			last := resp.Attempts[len(resp.Attempts)-1]
+
+			if last.Error != "" {
+				msg += ", last: " + last.Error
+			} else {
+				msg += ", last: " + statusText(last.StatusCode)
+			}
+		}
+
+		return category, msg
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return entity.ErrorCategoryHTTP, statusText(resp.StatusCode)
+	}
+
+	return "", ""
+}
+
+// errorText is the error message, tls errors start with the reason of the failure.
+func errorText(err error, category entity.ErrorCategory) string {
+	if category == entity.ErrorCategoryTLS {
+		if reason := tlsReason(err); reason != "" {
+			return reason + ": " + err.Error()
+		}
+	}
+
+	return err.Error()
+}
+
+func statusText(code int) string {
+	return strings.TrimSpace(fmt.Sprintf("%d %s", code, http.StatusText(code)))
+}
@@ -1,18 +1,22 @@
 package fetcher
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"math"
+	"net"
 	"net/http"
-	"strconv"
+	"net/http/cookiejar"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/auth"
 	"github.com/antonmisa/cliurlfetcher/pkg/logger"
 	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"golang.org/x/net/publicsuffix"
 )
 
 const (
@@ -21,6 +25,11 @@ const (
 	defaultIdleConnTimeout     = 30
 
 	defaultReadLimit = int64(128)
+
+	// the same as the transport limits of cleanhttp
+	defaultConnectTimeout      = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultKeepAlive           = 30 * time.Second
 )
 
 var (
@@ -29,14 +38,27 @@ var (
 )
 
 type FetcherRequest struct {
-	ID     string
-	Method string
-	URL    string
+	ID      string
+	Method  string
+	URL     string
+	Headers http.Header
+	Body    string
+
+	// Timeout limits a single attempt and Timeouts its phases, zero means fetcher default
+	Timeout  time.Duration
+	Timeouts entity.Timeouts
 
+	// ReadLimit of the content kept in response, zero means fetcher default
+	ReadLimit int64
+
+	// RetryWaitMin and RetryWaitMax bound the backoff, zero means the retry policy
 	RetryWaitMin time.Duration
 	RetryWaitMax time.Duration
 	MaxRetries   int
 
+	// Attempts made before, the next one is numbered after them
+	Attempts []entity.Attempt
+
 	Request *http.Request
 }
 
@@ -46,6 +68,82 @@ type FetcherResponse struct {
 	Content       string
 	ContentLength int64
 	Retries       int
+
+	// Body is saved to BodyPath if saving is enabled
+	BodyPath   string
+	BodySize   int64
+	BodySHA256 string
+
+	// Timing of the last attempt
+	Timing entity.Timing
+
+	// Proxy of the last attempt without password, empty for direct
+	Proxy string
+
+	// TLS of the last attempt
+	TLS entity.TLSInfo
+
+	// Redirects of the last attempt, FinalURL is the url of the final response
+	Redirects []entity.Redirect
+	FinalURL  string
+
+	// Attempts history, the last one is the final
+	Attempts []entity.Attempt
+
+	// Retry is set by Attempt when the request should be repeated after RetryIn
+	Retry   bool
+	RetryIn time.Duration
+
+	// Error of the final outcome with its category, http errors included
+	Error         string
+	ErrorCategory entity.ErrorCategory
+}
+
+// Options of the fetcher.
+type Options struct {
+	// ReadLimit of the content kept in response, zero means default
+	ReadLimit int64
+
+	// BodyDir to save complete bodies to, empty means no saving
+	BodyDir    string
+	BodyNaming BodyNaming
+
+	// Retry policy, zero policy is the same as DefaultRetryPolicy
+	Retry RetryPolicy
+
+	// Backoff between attempts, nil means DefaultBackoff
+	Backoff Backoff
+
+	// Breaker of the hosts failing in a row, zero options mean no breaker
+	Breaker BreakerOptions
+
+	// Headers are added to every request not having them, UserAgent too
+	Headers   http.Header
+	UserAgent string
+
+	// Cookies received are kept in a jar shared by all tasks and sent back to the hosts
+	Cookies bool
+
+	// Auth sets credentials of every attempt, nil means no auth
+	Auth auth.Provider
+
+	// Proxies are checked in order, the first matching the host wins,
+	// the environment settings are used when none matches
+	Proxies []ProxyRule
+
+	// TLS of connections, zero options mean system defaults
+	TLS TLSOptions
+
+	// CertWarnDays flags peer certificates expiring within them, zero means no check
+	CertWarnDays int
+
+	// Redirects policy, zero options follow up to 10 redirects
+	Redirects RedirectOptions
+
+	// Timeout of a single attempt and Timeouts of its phases, tasks could override them.
+	// Zero means no limit, except connect and tls handshake having 30s and 10s by default
+	Timeout  time.Duration
+	Timeouts entity.Timeouts
 }
 
 // CheckRetry specifies a policy for handling retries. It is called
@@ -60,12 +158,14 @@ type CheckRetry func(ctx context.Context, resp *http.Response, err error) (bool,
 
 // Backoff specifies a policy for how long to wait between retries.
 // It is called after a failing request to determine the amount of time
-// that should pass before trying again.
-type Backoff func(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration
+// that should pass before trying again. Prev is the wait chosen after
+// the previous attempt, zero after the first one.
+type Backoff func(min, max time.Duration, attemptNum int, prev time.Duration, resp *http.Response) time.Duration
 
 type Fetcher struct {
 	client *http.Client
 	logger logger.Interface
+	opts   Options
 
 	// CheckRetry specifies the policy for handling retries, and is called
 	// after each request. The default policy is DefaultRetryPolicy.
@@ -73,11 +173,42 @@ type Fetcher struct {
 
 	// Backoff specifies the policy for how long to wait between retries
 	backoff Backoff
+
+	// breaker is nil when disabled
+	breaker *breaker
+
+	// proxy of the transport, kept to report it
+	proxy proxyFunc
 }
 
-// NewRequest creates a new wrapped request.
-func NewRequest(ctx context.Context, method, url string) (*http.Request, error) {
-	return http.NewRequestWithContext(ctx, method, url, http.NoBody)
+// NewRequest creates a new wrapped request. Empty method means GET.
+func NewRequest(ctx context.Context, method, url string, headers http.Header, body string) (*http.Request, error) {
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var bodyReader io.Reader = http.NoBody
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range headers {
+		for _, v := range values {
+			request.Header.Add(name, v)
+		}
+	}
+
+	// Host header is ignored by the client, it has own field
+	if host := request.Header.Get("Host"); host != "" {
+		request.Host = host
+	}
+
+	return request, nil
 }
 
 // DefaultRetryPolicy provides a default callback for Client.CheckRetry, which
@@ -123,150 +254,512 @@ func baseRetryPolicy(resp *http.Response, err error) bool {
 // It also tries to parse Retry-After response header when a http.StatusTooManyRequests
 // (HTTP Code 429 or 503) is found in the resp parameter. Hence it will return the number of
 // seconds the server states it may be ready to process more requests from this client.
-func DefaultBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
-	if resp != nil {
-		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
-			if s, ok := resp.Header["Retry-After"]; ok {
-				if sleep, err := strconv.ParseInt(s[0], 10, 64); err == nil {
-					return time.Second * time.Duration(sleep)
-				}
-			}
-		}
+func DefaultBackoff(min, max time.Duration, attemptNum int, _ time.Duration, resp *http.Response) time.Duration {
+	if sleep, ok := retryAfter(resp); ok {
+		return sleep
 	}
 
-	mult := math.Pow(2, float64(attemptNum)) * float64(min)
-	sleep := time.Duration(mult)
-	if float64(sleep) != mult || sleep > max {
-		sleep = max
-	}
-	return sleep
+	return exponential(min, max, attemptNum)
 }
 
 func Constructor(l logger.Interface) Fetcher {
+	client := cleanhttp.DefaultPooledClient()
+	client.CheckRedirect = redirectPolicy{opts: RedirectOptions{MaxHops: defaultMaxRedirects}}.check
+
 	return Fetcher{
-		client: cleanhttp.DefaultPooledClient(),
+		client: client,
 		logger: l,
+		opts: Options{
+			ReadLimit:  defaultReadLimit,
+			BodyNaming: BodyNamingID,
+		},
 
 		checkRetry: DefaultRetryPolicy,
 		backoff:    DefaultBackoff,
+		proxy:      http.ProxyFromEnvironment,
+	}
+}
+
+// New returns fetcher configured by the options, zero options mean defaults.
+func New(opts Options, l logger.Interface) (Fetcher, error) {
+	f := Constructor(l)
+
+	if opts.ReadLimit < 0 {
+		return Fetcher{}, fmt.Errorf("negative read limit %d", opts.ReadLimit)
+	}
+
+	if opts.ReadLimit > 0 {
+		f.opts.ReadLimit = opts.ReadLimit
+	}
+
+	if opts.BodyNaming != "" {
+		f.opts.BodyNaming = opts.BodyNaming
+	}
+
+	f.opts.Retry = opts.Retry
+	f.checkRetry = opts.Retry.CheckRetry
+
+	if opts.Backoff != nil {
+		f.backoff = opts.Backoff
+	}
+
+	brk, err := newBreaker(opts.Breaker, l)
+	if err != nil {
+		return Fetcher{}, err
+	}
+
+	f.breaker = brk
+
+	f.opts.Auth = opts.Auth
+
+	if opts.CertWarnDays < 0 {
+		return Fetcher{}, fmt.Errorf("negative cert warn days %d", opts.CertWarnDays)
+	}
+
+	f.opts.CertWarnDays = opts.CertWarnDays
+
+	redirects, err := newRedirectPolicy(opts.Redirects)
+	if err != nil {
+		return Fetcher{}, err
+	}
+
+	f.client.CheckRedirect = redirects.check
+
+	t := opts.Timeouts
+	if opts.Timeout < 0 || t.Connect < 0 || t.TLSHandshake < 0 || t.ResponseHeader < 0 || t.BodyIdle < 0 {
+		return Fetcher{}, fmt.Errorf("negative timeout in %+v", opts.Timeouts)
+	}
+
+	f.opts.Timeout = opts.Timeout
+	f.opts.Timeouts = mergeTimeouts(entity.Timeouts{
+		Connect:      defaultConnectTimeout,
+		TLSHandshake: defaultTLSHandshakeTimeout,
+	}, opts.Timeouts)
+
+	transport, proxy, err := newTransport(opts.Proxies, opts.TLS)
+	if err != nil {
+		return Fetcher{}, err
+	}
+
+	f.client.Transport = transport
+
+	if proxy != nil {
+		f.proxy = proxy
+	}
+
+	f.opts.Headers = opts.Headers.Clone()
+	if opts.UserAgent != "" {
+		if f.opts.Headers == nil {
+			f.opts.Headers = http.Header{}
+		}
+
+		f.opts.Headers.Set("User-Agent", opts.UserAgent)
+	}
+
+	if opts.Cookies {
+		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if err != nil {
+			return Fetcher{}, fmt.Errorf("could't create cookie jar: %w", err)
+		}
+
+		f.client.Jar = jar
+	}
+
+	if opts.BodyDir != "" {
+		if err := os.MkdirAll(opts.BodyDir, 0o755); err != nil {
+			return Fetcher{}, fmt.Errorf("could't create body dir: %w", err)
+		}
+
+		f.opts.BodyDir = opts.BodyDir
+	}
+
+	return f, nil
+}
+
+// NewTransport returns transport routing requests by the proxies and tls options
+// the same way as the fetcher does, e.g. for clients getting auth tokens.
+func NewTransport(proxies []ProxyRule, opts TLSOptions) (http.RoundTripper, error) {
+	transport, _, err := newTransport(proxies, opts)
+
+	return transport, err
+}
+
+// newTransport returns the transport of the options with its proxy, nil proxy means environment settings.
+func newTransport(proxies []ProxyRule, opts TLSOptions) (http.RoundTripper, proxyFunc, error) {
+	transport := cleanhttp.DefaultPooledTransport()
+
+	// connect and tls handshake are bounded per attempt, so tasks could override the limits
+	transport.DialContext = (&net.Dialer{KeepAlive: defaultKeepAlive}).DialContext
+	transport.TLSHandshakeTimeout = 0
+
+	var proxy proxyFunc
+
+	if len(proxies) > 0 {
+		var err error
+
+		proxy, err = newProxyFunc(proxies)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transport.Proxy = proxy
+	}
+
+	// tls rules clone the transport, so it goes after the rest of its settings
+	if !opts.configured() {
+		return transport, proxy, nil
+	}
+
+	rt, err := newTLSTransport(transport, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rt, proxy, nil
+}
+
+// BreakerStats returns summary of the hosts whose circuit was opened, nil without breaker.
+func (f Fetcher) BreakerStats() []BreakerStat {
+	if f.breaker == nil {
+		return nil
 	}
+
+	return f.breaker.stats()
 }
 
+// timeout of the attempt, the task one wins.
+func (f Fetcher) timeout(req FetcherRequest) time.Duration {
+	if req.Timeout > 0 {
+		return req.Timeout
+	}
+
+	return f.opts.Timeout
+}
+
+// Get makes attempts of the request until it succeeds or attempts are over,
+// waiting between them. Use Attempt to make them one by one without waiting.
 func (f Fetcher) Get(ctx context.Context, req FetcherRequest) (FetcherResponse, error) {
 	op := "fetcher - Get"
 
-	request, err := NewRequest(ctx, req.Method, req.URL)
+	for {
+		resp, err := f.Attempt(ctx, req)
+		if !resp.Retry {
+			return resp, err
+		}
+
+		timer := time.NewTimer(resp.RetryIn)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			f.client.CloseIdleConnections()
+
+			f.logger.Info("%s - request %s canceled while waiting for retry", op, req.ID)
+
+			resp.Retry, resp.RetryIn = false, 0
+			resp.ErrorCategory, resp.Error = describe(resp, ctx.Err())
+
+			return resp, ctx.Err()
+		case <-timer.C:
+		}
+
+		req.Attempts = resp.Attempts
+	}
+}
+
+// Attempt makes the next attempt of the request, req.Attempts is the history of
+// previous ones. When the request should be repeated, the response has Retry set,
+// RetryIn is the wait before the next attempt and the error is nil.
+func (f Fetcher) Attempt(ctx context.Context, req FetcherRequest) (FetcherResponse, error) {
+	op := "fetcher - Attempt"
+
+	request, err := NewRequest(ctx, req.Method, req.URL, req.Headers, req.Body)
 	if err != nil {
 		f.logger.Error("%s - NewRequest: %w", op, err)
 
 		return FetcherResponse{
-			ID: req.ID,
+			ID:            req.ID,
+			Retries:       len(req.Attempts),
+			Attempts:      req.Attempts,
+			Error:         err.Error(),
+			ErrorCategory: entity.ErrorCategoryInvalidRequest,
 		}, err
 	}
 
+	// task headers win over the defaults
+	for name, values := range f.opts.Headers {
+		if _, ok := request.Header[name]; !ok {
+			request.Header[name] = append([]string(nil), values...)
+		}
+	}
+
 	req.Request = request
 
-	return f.do(req)
+	var resp FetcherResponse
+
+	// credentials are set on every attempt, the token could be refreshed between them
+	if f.opts.Auth != nil {
+		err = f.opts.Auth.Authorize(ctx, request)
+	}
+
+	if err != nil {
+		f.logger.Error("%s - Authorize request %s: %v", op, req.ID, err)
+
+		resp, err = f.unauthorized(req, err)
+	} else {
+		resp, err = f.do(req)
+	}
+
+	if !resp.Retry {
+		resp.ErrorCategory, resp.Error = describe(resp, err)
+	}
+
+	return resp, err
+}
+
+// unauthorized records the attempt failed to get credentials and decides on the next one,
+// the failure is retried as transport errors are.
+func (f Fetcher) unauthorized(req FetcherRequest, authErr error) (FetcherResponse, error) {
+	op := "fetcher - unauthorized"
+
+	attempt := len(req.Attempts) + 1
+
+	result := FetcherResponse{
+		ID:       req.ID,
+		Retries:  len(req.Attempts),
+		Attempts: req.Attempts,
+	}
+
+	if attempt > req.MaxRetries {
+		return result, fmt.Errorf("%s - attempts is over for request %s: %w", op, req.ID, ErrNoMoreAttempts)
+	}
+
+	// history is shared with the caller, never append to it in place
+	attempts := make([]entity.Attempt, len(req.Attempts), attempt)
+	copy(attempts, req.Attempts)
+
+	var lastWait time.Duration
+	if len(req.Attempts) > 0 {
+		lastWait = req.Attempts[len(req.Attempts)-1].Backoff
+	}
+
+	current := entity.Attempt{
+		Number:        attempt,
+		Started:       time.Now(),
+		ErrorCategory: Classify(authErr, nil),
+	}
+	current.Error = errorText(authErr, current.ErrorCategory)
+
+	result.Retries = attempt
+
+	// nothing is sent yet, so requests not being idempotent are retried too
+	shouldRetry, err := f.checkRetry(req.Request.Context(), nil, authErr)
+	if !shouldRetry || err != nil {
+		result.Attempts = append(attempts, current)
+
+		return result, err
+	}
+
+	if attempt == req.MaxRetries {
+		result.Attempts = append(attempts, current)
+
+		return result, fmt.Errorf("%s - attempts is over for request %s: %w", op, req.ID, ErrNoMoreAttempts)
+	}
+
+	waitMin, waitMax := f.opts.Retry.waits(req)
+
+	current.Backoff = f.backoff(waitMin, waitMax, attempt, lastWait, nil)
+
+	result.Attempts = append(attempts, current)
+	result.Retry = true
+	result.RetryIn = current.Backoff
+
+	return result, nil
 }
 
+// do makes single attempt of the prepared request and decides on the next one.
 func (f Fetcher) do(req FetcherRequest) (FetcherResponse, error) {
-	op := "fetcher - DoWithContext"
+	op := "fetcher - do"
+
+	attempt := len(req.Attempts) + 1
+
+	result := FetcherResponse{
+		ID:       req.ID,
+		Retries:  len(req.Attempts),
+		Attempts: req.Attempts,
+	}
+
+	if attempt > req.MaxRetries {
+		return result, fmt.Errorf("%s - attempts is over for request %s: %w", op, req.ID, ErrNoMoreAttempts)
+	}
+
+	// history is shared with the caller, never append to it in place
+	attempts := make([]entity.Attempt, len(req.Attempts), attempt)
+	copy(attempts, req.Attempts)
+
+	var lastWait time.Duration
+	if len(req.Attempts) > 0 {
+		lastWait = req.Attempts[len(req.Attempts)-1].Backoff
+	}
+
+	// the host is down, do not waste attempts on it
+	host := breakerKey(req.Request)
+	if f.breaker != nil && !f.breaker.allow(host) {
+		err := fmt.Errorf("%w for host %s", ErrCircuitOpen, host)
+
+		result.Retries = attempt
+		result.Attempts = append(attempts, entity.Attempt{
+			Number:        attempt,
+			Started:       time.Now(),
+			Error:         err.Error(),
+			ErrorCategory: entity.ErrorCategoryCircuitOpen,
+		})
+
+		return result, err
+	}
 
-	var lastStatusCode int
+	f.logger.Info("%s - request %s starting attempt %d", op, req.ID, attempt)
 
-	var lastContentLength int64
+	// Attempt the request, bounded by the timeouts of the task or the fetcher
+	tr := newTracer()
 
-	var attempt int
+	ctx, chain := withRedirectChain(tr.context(req.Request.Context()))
 
-	for attempt = 1; attempt <= req.MaxRetries; attempt++ {
-		f.logger.Info("%s - request %s starting attempt %d", op, req.ID, attempt)
+	ctx, dl, cancel := withDeadlines(ctx, f.timeout(req), mergeTimeouts(f.opts.Timeouts, req.Timeouts))
+	defer cancel()
 
-		// Attempt the request
-		resp, err := f.client.Do(req.Request)
+	result.Proxy = f.proxyOf(req.Request)
 
+	resp, err := f.client.Do(req.Request.WithContext(ctx))
+	err = timeoutCause(ctx, err)
+
+	current := entity.Attempt{
+		Number:  attempt,
+		Started: tr.start,
+	}
+
+	current.ErrorCategory = Classify(err, resp)
+
+	if err != nil {
+		current.Error = errorText(err, current.ErrorCategory)
+	}
+
+	result.Retries = attempt
+
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		result.ContentLength = resp.ContentLength
+		current.StatusCode = resp.StatusCode
+
+		result.TLS = tlsInfo(resp.TLS, time.Now(), f.opts.CertWarnDays)
+		result.FinalURL = resp.Request.URL.String()
+	}
+
+	result.Redirects = chain.list()
+
+	if f.breaker != nil {
+		f.breaker.record(host, current.ErrorCategory, current.StatusCode)
+	}
+
+	// Kept even without retry, the limiter slows down the host by it
+	requested, hasRetryAfter := retryAfter(resp)
+	current.RetryAfter = requested
+
+	// Check for retry if possible
+	reqErr := err
+
+	shouldRetry, err := f.checkRetry(req.Request.Context(), resp, err)
+	if shouldRetry && !f.opts.Retry.canRetry(req.Request) {
+		f.logger.Info("%s - request %s is not idempotent, no retry", op, req.ID)
+
+		shouldRetry, err = false, reqErr
+	}
+
+	if !shouldRetry || err != nil {
+		f.logger.Info("%s - request %s stopped at attempt %d: %w", op, req.URL, attempt, err)
+
+		var content string
+
+		var body bodyInfo
+
+		var errDrain error
+
+		// stop and return request as-is
 		if resp != nil {
-			lastStatusCode = resp.StatusCode
-			lastContentLength = resp.ContentLength
+			content, body, errDrain = f.drainBody(req, dl.body(resp.Body), true)
+		} else if err != nil {
+			err = fmt.Errorf("%w: %w", ErrExternalRoutingError, err)
 		}
 
-		// Check for retry if possible
-		shouldRetry, err := f.checkRetry(req.Request.Context(), resp, err)
-		if !shouldRetry || err != nil {
-			f.logger.Info("%s - request %s stopped at attempt %d: %w", op, req.URL, attempt, err)
+		current.Timing = tr.timing(time.Now())
 
-			var content string
+		result.Timing = current.Timing
+		result.Attempts = append(attempts, current)
 
-			var errDrain error
+		if errDrain != nil {
+			f.logger.Error("%s - f.drainBody request %s: %w", op, req.ID, errDrain)
 
-			// stop and return request as-is
-			if resp != nil {
-				content, errDrain = f.drainBody(resp.Body)
-			} else if err != nil {
-				content = ErrExternalRoutingError.Error()
-			}
+			result.ContentLength = 0
 
-			if errDrain != nil {
-				f.logger.Error("%s - f.drainBody request %s: %w", op, req.ID, errDrain)
+			// the body stalled, the attempt is reported as timed out
+			if errDrain = timeoutCause(ctx, errDrain); errors.Is(errDrain, ErrTimeout) {
+				err = errDrain
 
-				return FetcherResponse{
-					ID:         req.ID,
-					StatusCode: lastStatusCode,
-					Retries:    attempt,
-				}, err
+				current.ErrorCategory = entity.ErrorCategoryTimeout
+				current.Error = err.Error()
+				result.Attempts[len(result.Attempts)-1] = current
 			}
 
-			return FetcherResponse{
-				ID:            req.ID,
-				StatusCode:    lastStatusCode,
-				Content:       content,
-				ContentLength: lastContentLength,
-				Retries:       attempt,
-			}, err
+			return result, err
 		}
 
-		f.drainBody(resp.Body)
+		result.Content = content
+		result.BodyPath = body.path
+		result.BodySize = body.size
+		result.BodySHA256 = body.sha256
 
-		wait := f.backoff(req.RetryWaitMin, req.RetryWaitMax, attempt, resp)
+		return result, err
+	}
 
-		timer := time.NewTimer(wait)
-		select {
-		case <-req.Request.Context().Done():
-			timer.Stop()
+	if resp != nil {
+		f.drainBody(req, resp.Body, false)
+	}
 
-			f.client.CloseIdleConnections()
+	current.Timing = tr.timing(time.Now())
+	result.Timing = current.Timing
 
-			return FetcherResponse{
-				ID:            req.ID,
-				StatusCode:    lastStatusCode,
-				Retries:       attempt,
-				ContentLength: lastContentLength,
-			}, req.Request.Context().Err()
-		case <-timer.C:
-		}
+	// no wait after the last attempt, all attempts is gone, but nothing good happens
+	if attempt == req.MaxRetries {
+		result.Attempts = append(attempts, current)
+
+		return result, fmt.Errorf("%s - attempts is over for request %s: %w", op, req.ID, ErrNoMoreAttempts)
 	}
 
-	// all attempts is gone, but nothing good happens
-	return FetcherResponse{
-		ID:            req.ID,
-		StatusCode:    lastStatusCode,
-		Retries:       attempt,
-		ContentLength: lastContentLength,
-	}, fmt.Errorf("%s - attempts is over for request %s: %w", op, req.ID, ErrNoMoreAttempts)
-}
+	waitMin, waitMax := f.opts.Retry.waits(req)
 
-// Try to read the response body so we can reuse this connection.
-func (f *Fetcher) drainBody(body io.ReadCloser) (string, error) {
-	defer body.Close()
+	wait := f.backoff(waitMin, waitMax, attempt, lastWait, resp)
 
-	op := "fetcher - drainBody"
+	if hasRetryAfter {
+		if limit := f.opts.Retry.MaxRetryAfter; limit > 0 && wait > limit {
+			if f.opts.Retry.AbandonLongRetryAfter {
+				f.logger.Info("%s - request %s abandoned, server asked to wait %s", op, req.ID, requested)
 
-	writer := bytes.NewBufferString("")
+				current.Abandoned = true
+				result.Attempts = append(attempts, current)
 
-	_, err := io.Copy(writer, io.LimitReader(body, defaultReadLimit))
-	if err != nil {
-		f.logger.Error("%s - io.Copy: %w", op, err)
-		return "", err
+				return result, fmt.Errorf("%w: %s, limit is %s", ErrRetryAfterTooLong, requested, limit)
+			}
+
+			wait = limit
+			current.WaitTruncated = true
+		}
 	}
 
-	return writer.String(), err
+	current.Backoff = wait
+
+	result.Attempts = append(attempts, current)
+	result.Retry = true
+	result.RetryIn = wait
+
+	return result, nil
 }
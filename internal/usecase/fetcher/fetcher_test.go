@@ -2,10 +2,21 @@ package fetcher
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/auth"
+	"github.com/antonmisa/cliurlfetcher/pkg/httpheader"
 	"github.com/antonmisa/cliurlfetcher/pkg/logger"
 	"github.com/stretchr/testify/require"
 )
@@ -66,6 +77,8 @@ func TestFetcher_Get(t *testing.T) {
 				Content:       "404 - page not found",
 				ContentLength: 20,
 				Retries:       1,
+				Error:         "404 Not Found",
+				ErrorCategory: entity.ErrorCategoryHTTP,
 			},
 		},
 	}
@@ -82,6 +95,7 @@ func TestFetcher_Get(t *testing.T) {
 			defer func() { testServer.Close() }()
 
 			tc.args.req.URL = testServer.URL
+			tc.want.FinalURL = testServer.URL
 
 			got, err := tc.f().Get(tc.args.ctx, tc.args.req)
 			if tc.wantErr {
@@ -90,7 +104,886 @@ func TestFetcher_Get(t *testing.T) {
 				require.NoError(t, err)
 			}
 
+			// timing is checked by TestFetcher_GetTiming, attempts by TestFetcher_GetAttempts
+			require.Positive(t, got.Timing.Total)
+			got.Timing = entity.Timing{}
+
+			require.Len(t, got.Attempts, got.Retries)
+			got.Attempts = nil
+
 			require.Equal(t, got, tc.want)
 		})
 	}
 }
+
+func TestFetcher_GetWithHeadersAndBody(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(req.Method + " " + req.Header.Get("X-Token") + " " + string(body)))
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	got, err := Constructor(l).Get(context.Background(), FetcherRequest{
+		ID:         "1",
+		URL:        testServer.URL,
+		Method:     http.MethodPost,
+		Headers:    http.Header{"X-Token": {"secret"}},
+		Body:       `{"a":1}`,
+		MaxRetries: 3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, got.StatusCode)
+	require.Equal(t, `POST secret {"a":1}`, got.Content)
+}
+
+func TestFetcher_GetReadLimitAndSaveBody(t *testing.T) {
+	t.Parallel()
+
+	const content = "0123456789abcdef"
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(content))
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	dir := t.TempDir()
+
+	f, err := New(Options{ReadLimit: 4, BodyDir: dir}, l)
+	require.NoError(t, err)
+
+	got, err := f.Get(context.Background(), FetcherRequest{
+		ID:         "list.csv:1",
+		URL:        testServer.URL,
+		MaxRetries: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "0123", got.Content)
+	idSum := sha256.Sum256([]byte("list.csv:1"))
+	require.Equal(t, filepath.Join(dir, "list.csv_1_"+hex.EncodeToString(idSum[:8])+".body"), got.BodyPath)
+	require.Equal(t, int64(len(content)), got.BodySize)
+
+	sum := sha256.Sum256([]byte(content))
+	require.Equal(t, hex.EncodeToString(sum[:]), got.BodySHA256)
+
+	saved, err := os.ReadFile(got.BodyPath)
+	require.NoError(t, err)
+	require.Equal(t, content, string(saved))
+
+	got, err = f.Get(context.Background(), FetcherRequest{
+		ID:         "2",
+		URL:        testServer.URL,
+		MaxRetries: 1,
+		ReadLimit:  8,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "01234567", got.Content)
+	require.Equal(t, filepath.Join(dir, "2.body"), got.BodyPath)
+
+	// ids replaced to the same name are saved to different files
+	paths := map[string]bool{}
+
+	for _, id := range []string{"a/b.csv:1", "a_b.csv_1", "a_b.csv_1_"} {
+		got, err = f.Get(context.Background(), FetcherRequest{ID: id, URL: testServer.URL, MaxRetries: 1})
+		require.NoError(t, err)
+
+		paths[got.BodyPath] = true
+	}
+
+	require.Len(t, paths, 3)
+
+	// requests to the same url are saved to different files by hash too
+	f, err = New(Options{BodyDir: dir, BodyNaming: BodyNamingHash}, l)
+	require.NoError(t, err)
+
+	paths = map[string]bool{}
+
+	for _, req := range []FetcherRequest{
+		{ID: "1", URL: testServer.URL},
+		{ID: "1", URL: testServer.URL, Method: http.MethodPost},
+		{ID: "2", URL: testServer.URL},
+	} {
+		req.MaxRetries = 1
+
+		got, err = f.Get(context.Background(), req)
+		require.NoError(t, err)
+
+		paths[got.BodyPath] = true
+	}
+
+	require.Len(t, paths, 3)
+}
+
+func TestFetcher_GetTiming(t *testing.T) {
+	t.Parallel()
+
+	const delay = 20 * time.Millisecond
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		time.Sleep(delay)
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	u, err := url.Parse(testServer.URL)
+	require.NoError(t, err)
+
+	// resolve localhost to get dns phase
+	u.Host = "localhost:" + u.Port()
+
+	l, _ := logger.NewFake()
+
+	got, err := Constructor(l).Get(context.Background(), FetcherRequest{
+		ID:         "1",
+		URL:        u.String(),
+		MaxRetries: 1,
+	})
+	require.NoError(t, err)
+
+	require.Positive(t, got.Timing.DNSLookup)
+	require.Positive(t, got.Timing.Connect)
+	require.Zero(t, got.Timing.TLSHandshake)
+	require.GreaterOrEqual(t, got.Timing.TimeToFirstByte, delay)
+	require.GreaterOrEqual(t, got.Timing.Total, got.Timing.TimeToFirstByte+got.Timing.Connect)
+	require.False(t, got.Timing.ConnReused)
+
+	// phases are of the final hop, the redirect kept the connection
+	redirectServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/start" {
+			time.Sleep(delay)
+			http.Redirect(res, req, "/final", http.StatusMovedPermanently)
+
+			return
+		}
+
+		time.Sleep(2 * delay)
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer redirectServer.Close()
+
+	got, err = Constructor(l).Get(context.Background(), FetcherRequest{
+		ID:         "2",
+		URL:        redirectServer.URL + "/start",
+		MaxRetries: 1,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, got.Redirects, 1)
+	require.True(t, got.Timing.ConnReused)
+	require.Zero(t, got.Timing.Connect)
+	require.GreaterOrEqual(t, got.Timing.TimeToFirstByte, 2*delay)
+	require.Less(t, got.Timing.ContentTransfer, delay)
+	require.GreaterOrEqual(t, got.Timing.Total, got.Timing.TimeToFirstByte+delay)
+}
+
+func TestFetcher_GetAttempts(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) < 3 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	got, err := Constructor(l).Get(context.Background(), FetcherRequest{
+		ID:           "1",
+		URL:          testServer.URL,
+		MaxRetries:   3,
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, got.StatusCode)
+	require.Equal(t, 3, got.Retries)
+	require.Len(t, got.Attempts, 3)
+
+	for i, want := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		require.Equal(t, i+1, got.Attempts[i].Number)
+		require.Equal(t, want, got.Attempts[i].StatusCode)
+		require.Positive(t, got.Attempts[i].Timing.Total)
+	}
+
+	require.Equal(t, 2*time.Millisecond, got.Attempts[0].Backoff)
+	require.Equal(t, 4*time.Millisecond, got.Attempts[1].Backoff)
+	require.Zero(t, got.Attempts[2].Backoff)
+
+	// all attempts failed
+	calls.Store(-10)
+
+	got, err = Constructor(l).Get(context.Background(), FetcherRequest{
+		ID:           "2",
+		URL:          testServer.URL,
+		MaxRetries:   2,
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: 10 * time.Millisecond,
+	})
+	require.ErrorIs(t, err, ErrNoMoreAttempts)
+	require.Equal(t, 2, got.Retries)
+	require.Len(t, got.Attempts, 2)
+	require.Zero(t, got.Attempts[1].Backoff)
+}
+
+func TestFetcher_GetErrorCategory(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/loop":
+			http.Redirect(res, req, "/loop", http.StatusFound)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/busy":
+			res.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	// certificate of the server is not trusted by the client
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	// nothing listens on the port of closed server
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  FetcherRequest
+		want entity.ErrorCategory
+	}{
+		{
+			name: "http error",
+			req:  FetcherRequest{URL: testServer.URL + "/nope"},
+			want: entity.ErrorCategoryHTTP,
+		},
+		{
+			name: "dns",
+			req:  FetcherRequest{URL: "http://nonexistent.invalid/"},
+			want: entity.ErrorCategoryDNS,
+		},
+		{
+			name: "connection refused",
+			req:  FetcherRequest{URL: closedServer.URL},
+			want: entity.ErrorCategoryConnectionRefused,
+		},
+		{
+			name: "timeout",
+			req:  FetcherRequest{URL: testServer.URL + "/slow", Timeout: 10 * time.Millisecond},
+			want: entity.ErrorCategoryTimeout,
+		},
+		{
+			name: "too many redirects",
+			req:  FetcherRequest{URL: testServer.URL + "/loop"},
+			want: entity.ErrorCategoryTooManyRedirects,
+		},
+		{
+			name: "tls",
+			req:  FetcherRequest{URL: tlsServer.URL},
+			want: entity.ErrorCategoryTLS,
+		},
+		{
+			name: "context canceled",
+			ctx:  canceled,
+			req:  FetcherRequest{URL: testServer.URL},
+			want: entity.ErrorCategoryContextCanceled,
+		},
+		{
+			name: "retries exhausted",
+			req:  FetcherRequest{URL: testServer.URL + "/busy", MaxRetries: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond},
+			want: entity.ErrorCategoryRetriesExhausted,
+		},
+		{
+			name: "invalid request",
+			req:  FetcherRequest{URL: "http://[::1", Method: "BAD METHOD"},
+			want: entity.ErrorCategoryInvalidRequest,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			l, _ := logger.NewFake()
+
+			ctx := tc.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			if tc.req.MaxRetries == 0 {
+				tc.req.MaxRetries = 1
+			}
+
+			got, _ := Constructor(l).Get(ctx, tc.req)
+			require.Equal(t, tc.want, got.ErrorCategory, got.Error)
+			require.NotEmpty(t, got.Error)
+		})
+	}
+}
+
+func TestFetcher_GetRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/loop" {
+			http.Redirect(res, req, "/loop", http.StatusFound)
+			return
+		}
+
+		res.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	serverErrors := []StatusRange{{From: 500, To: 599}}
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		req      FetcherRequest
+		attempts int
+		category entity.ErrorCategory
+	}{
+		{
+			name:     "default policy",
+			req:      FetcherRequest{URL: testServer.URL},
+			attempts: 1,
+			category: entity.ErrorCategoryHTTP,
+		},
+		{
+			name:     "retryable status",
+			policy:   RetryPolicy{Statuses: serverErrors},
+			req:      FetcherRequest{URL: testServer.URL},
+			attempts: 3,
+			category: entity.ErrorCategoryRetriesExhausted,
+		},
+		{
+			name:     "non idempotent",
+			policy:   RetryPolicy{Statuses: serverErrors},
+			req:      FetcherRequest{URL: testServer.URL, Method: http.MethodPost},
+			attempts: 1,
+			category: entity.ErrorCategoryHTTP,
+		},
+		{
+			name:     "idempotency key",
+			policy:   RetryPolicy{Statuses: serverErrors},
+			req:      FetcherRequest{URL: testServer.URL, Method: http.MethodPost, Headers: http.Header{"Idempotency-Key": {"1"}}},
+			attempts: 3,
+			category: entity.ErrorCategoryRetriesExhausted,
+		},
+		{
+			name:     "non idempotent allowed",
+			policy:   RetryPolicy{Statuses: serverErrors, NonIdempotent: true},
+			req:      FetcherRequest{URL: testServer.URL, Method: http.MethodPost},
+			attempts: 3,
+			category: entity.ErrorCategoryRetriesExhausted,
+		},
+		{
+			name:     "transport error retried by default",
+			req:      FetcherRequest{URL: closedServer.URL},
+			attempts: 3,
+			category: entity.ErrorCategoryRetriesExhausted,
+		},
+		{
+			name:     "transport error not retried",
+			policy:   RetryPolicy{Errors: []entity.ErrorCategory{}},
+			req:      FetcherRequest{URL: closedServer.URL},
+			attempts: 1,
+			category: entity.ErrorCategoryConnectionRefused,
+		},
+		{
+			name:     "retryable error",
+			policy:   RetryPolicy{Errors: []entity.ErrorCategory{entity.ErrorCategoryConnectionRefused}},
+			req:      FetcherRequest{URL: closedServer.URL},
+			attempts: 3,
+			category: entity.ErrorCategoryRetriesExhausted,
+		},
+		{
+			name:     "redirect loop not retried by default",
+			req:      FetcherRequest{URL: testServer.URL + "/loop"},
+			attempts: 1,
+			category: entity.ErrorCategoryTooManyRedirects,
+		},
+		{
+			name:     "redirect loop retried",
+			policy:   RetryPolicy{Errors: []entity.ErrorCategory{entity.ErrorCategoryTooManyRedirects}},
+			req:      FetcherRequest{URL: testServer.URL + "/loop"},
+			attempts: 3,
+			category: entity.ErrorCategoryRetriesExhausted,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			l, _ := logger.NewFake()
+
+			tc.policy.WaitMin = time.Millisecond
+			tc.policy.WaitMax = time.Millisecond
+
+			f, err := New(Options{Retry: tc.policy}, l)
+			require.NoError(t, err)
+
+			tc.req.MaxRetries = 3
+
+			got, _ := f.Get(context.Background(), tc.req)
+			require.Len(t, got.Attempts, tc.attempts)
+			require.Equal(t, tc.category, got.ErrorCategory, got.Error)
+		})
+	}
+}
+
+func TestParseStatusRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    StatusRange
+		wantErr bool
+	}{
+		{in: "503", want: StatusRange{From: 503, To: 503}},
+		{in: "500-504", want: StatusRange{From: 500, To: 504}},
+		{in: "5xx", want: StatusRange{From: 500, To: 599}},
+		{in: " 4XX ", want: StatusRange{From: 400, To: 499}},
+		{in: "504-500", wantErr: true},
+		{in: "6xx", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseStatusRange(tc.in)
+		if tc.wantErr {
+			require.ErrorIs(t, err, ErrBadStatusRange, tc.in)
+			continue
+		}
+
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestParseErrorCategories(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseErrorCategories(nil)
+	require.NoError(t, err)
+	require.Nil(t, got)
+
+	got, err = ParseErrorCategories([]string{" Timeout", "dns_failure"})
+	require.NoError(t, err)
+	require.Equal(t, []entity.ErrorCategory{entity.ErrorCategoryTimeout, entity.ErrorCategoryDNS}, got)
+
+	got, err = ParseErrorCategories([]string{RetryErrorsNone})
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	require.Empty(t, got)
+
+	_, err = ParseErrorCategories([]string{"http_error"})
+	require.ErrorIs(t, err, ErrNotRetryableReason)
+}
+
+func TestNewBackoff(t *testing.T) {
+	t.Parallel()
+
+	const (
+		min = 10 * time.Millisecond
+		max = time.Second
+	)
+
+	tests := []struct {
+		strategy BackoffStrategy
+		// bounds of the wait after the attempt, prev is the previous wait
+		check func(t *testing.T, attempt int, prev, got time.Duration)
+	}{
+		{
+			strategy: BackoffExponential,
+			check: func(t *testing.T, attempt int, _, got time.Duration) {
+				require.Equal(t, exponential(min, max, attempt), got)
+			},
+		},
+		{
+			strategy: BackoffConstant,
+			check: func(t *testing.T, _ int, _, got time.Duration) {
+				require.Equal(t, min, got)
+			},
+		},
+		{
+			strategy: BackoffLinear,
+			check: func(t *testing.T, attempt int, _, got time.Duration) {
+				require.Equal(t, time.Duration(attempt)*min, got)
+			},
+		},
+		{
+			strategy: BackoffFullJitter,
+			check: func(t *testing.T, attempt int, _, got time.Duration) {
+				require.GreaterOrEqual(t, got, time.Duration(0))
+				require.Less(t, got, exponential(min, max, attempt))
+			},
+		},
+		{
+			strategy: BackoffEqualJitter,
+			check: func(t *testing.T, attempt int, _, got time.Duration) {
+				require.GreaterOrEqual(t, got, exponential(min, max, attempt)/2)
+				require.Less(t, got, exponential(min, max, attempt))
+			},
+		},
+		{
+			strategy: BackoffDecorrelated,
+			check: func(t *testing.T, _ int, prev, got time.Duration) {
+				if prev < min {
+					prev = min
+				}
+
+				require.GreaterOrEqual(t, got, min)
+				require.LessOrEqual(t, got, 3*prev)
+				require.LessOrEqual(t, got, max)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			t.Parallel()
+
+			b := NewBackoff(tc.strategy, 42)
+			same := NewBackoff(tc.strategy, 42)
+
+			var prev time.Duration
+
+			for attempt := 1; attempt <= 8; attempt++ {
+				got := b(min, max, attempt, prev, nil)
+				tc.check(t, attempt, prev, got)
+
+				// the same seed gives the same waits
+				require.Equal(t, got, same(min, max, attempt, prev, nil))
+
+				prev = got
+			}
+
+			// server asked to wait
+			resp := &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Header:     http.Header{"Retry-After": {"3"}},
+			}
+			require.Equal(t, 3*time.Second, b(min, max, 1, 0, resp))
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		status int
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "seconds", status: http.StatusServiceUnavailable, value: "120", want: 2 * time.Minute, wantOk: true},
+		{name: "date", status: http.StatusTooManyRequests, value: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), want: time.Hour, wantOk: true},
+		{name: "date in past", status: http.StatusTooManyRequests, value: "Sun, 06 Nov 1994 08:49:37 GMT", want: 0, wantOk: true},
+		{name: "negative", status: http.StatusServiceUnavailable, value: "-1"},
+		{name: "garbage", status: http.StatusServiceUnavailable, value: "soon"},
+		{name: "not retryable status", status: http.StatusInternalServerError, value: "120"},
+		{name: "no header", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		resp := &http.Response{StatusCode: tc.status, Header: http.Header{}}
+		if tc.value != "" {
+			resp.Header.Set("Retry-After", tc.value)
+		}
+
+		got, ok := retryAfter(resp)
+		require.Equal(t, tc.wantOk, ok, tc.name)
+		// date has a second precision
+		require.InDelta(t, tc.want, got, float64(time.Second), tc.name)
+	}
+}
+
+func TestFetcher_GetRetryAfterLimit(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if hits.Add(1)%2 == 1 {
+			res.Header().Set("Retry-After", "86400")
+			res.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	req := FetcherRequest{ID: "1", URL: testServer.URL, MaxRetries: 3}
+
+	truncating, err := New(Options{Retry: RetryPolicy{MaxRetryAfter: 5 * time.Millisecond}}, l)
+	require.NoError(t, err)
+
+	got, err := truncating.Get(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, got.StatusCode)
+	require.Len(t, got.Attempts, 2)
+	require.True(t, got.Attempts[0].WaitTruncated)
+	require.Equal(t, 24*time.Hour, got.Attempts[0].RetryAfter)
+	require.Equal(t, 5*time.Millisecond, got.Attempts[0].Backoff)
+
+	abandoning, err := New(Options{Retry: RetryPolicy{MaxRetryAfter: 5 * time.Millisecond, AbandonLongRetryAfter: true}}, l)
+	require.NoError(t, err)
+
+	got, err = abandoning.Get(context.Background(), req)
+	require.ErrorIs(t, err, ErrRetryAfterTooLong)
+	require.Equal(t, entity.ErrorCategoryRetryAfterTooLong, got.ErrorCategory)
+	require.Equal(t, http.StatusServiceUnavailable, got.StatusCode)
+	require.Len(t, got.Attempts, 1)
+	require.Equal(t, 24*time.Hour, got.Attempts[0].RetryAfter)
+	require.True(t, got.Attempts[0].Abandoned)
+}
+
+func TestFetcher_Attempt(t *testing.T) {
+	t.Parallel()
+
+	var hits atomic.Int32
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if hits.Add(1) == 1 {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	f := Constructor(l)
+
+	req := FetcherRequest{
+		ID:           "1",
+		URL:          testServer.URL,
+		RetryWaitMin: time.Hour,
+		RetryWaitMax: time.Hour,
+		MaxRetries:   2,
+	}
+
+	// no waiting inside, the wait is returned instead
+	got, err := f.Attempt(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, got.Retry)
+	require.Equal(t, time.Hour, got.RetryIn)
+	require.Equal(t, 1, got.Retries)
+	require.Empty(t, got.ErrorCategory)
+
+	req.Attempts = got.Attempts
+
+	got, err = f.Attempt(context.Background(), req)
+	require.NoError(t, err)
+	require.False(t, got.Retry)
+	require.Equal(t, http.StatusOK, got.StatusCode)
+	require.Equal(t, 2, got.Retries)
+	require.Len(t, got.Attempts, 2)
+	require.Equal(t, 2, got.Attempts[1].Number)
+
+	// history is over
+	req.Attempts = got.Attempts
+
+	_, err = f.Attempt(context.Background(), req)
+	require.ErrorIs(t, err, ErrNoMoreAttempts)
+}
+
+func TestFetcher_Breaker(t *testing.T) {
+	t.Parallel()
+
+	var (
+		hits atomic.Int32
+		down atomic.Bool
+	)
+
+	down.Store(true)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+
+		if down.Load() {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	f, err := New(Options{
+		Breaker: BreakerOptions{Failures: 2, Cooldown: 50 * time.Millisecond},
+	}, l)
+	require.NoError(t, err)
+
+	req := FetcherRequest{ID: "1", URL: testServer.URL, MaxRetries: 1}
+
+	for i := 0; i < 2; i++ {
+		got, _ := f.Get(context.Background(), req)
+		require.Equal(t, http.StatusInternalServerError, got.StatusCode)
+	}
+
+	// open, the server is not asked
+	got, err := f.Get(context.Background(), req)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.Equal(t, entity.ErrorCategoryCircuitOpen, got.ErrorCategory)
+	require.Len(t, got.Attempts, 1)
+	require.Equal(t, int32(2), hits.Load())
+
+	// the probe fails and opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+
+	got, _ = f.Get(context.Background(), req)
+	require.Equal(t, http.StatusInternalServerError, got.StatusCode)
+
+	_, err = f.Get(context.Background(), req)
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	// the probe succeeds and closes the circuit
+	down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		got, err = f.Get(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, got.StatusCode)
+	}
+
+	host := breakerKey(httptest.NewRequest(http.MethodGet, testServer.URL, nil))
+	require.Equal(t, []BreakerStat{{Host: host, State: BreakerClosed, Opened: 2, FastFailed: 2}}, f.BreakerStats())
+}
+
+func TestFetcher_HeadersAndCookies(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			http.SetCookie(res, &http.Cookie{Name: "session", Value: "42", Path: "/"})
+			return
+		}
+
+		cookie, err := req.Cookie("session")
+		if err != nil {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		res.Write([]byte(req.UserAgent() + " " + req.Header.Get("X-Api-Key") + " " + cookie.Value))
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	headers, err := httpheader.ParseLines([]string{"X-Api-Key: default", "Accept: */*"})
+	require.NoError(t, err)
+
+	f, err := New(Options{Headers: headers, UserAgent: "fetcher/1.0", Cookies: true}, l)
+	require.NoError(t, err)
+
+	got, err := f.Get(context.Background(), FetcherRequest{ID: "1", URL: testServer.URL + "/data", MaxRetries: 1})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, got.StatusCode)
+
+	_, err = f.Get(context.Background(), FetcherRequest{ID: "2", URL: testServer.URL + "/login", MaxRetries: 1})
+	require.NoError(t, err)
+
+	// the cookie of another task is sent, the task header wins
+	got, err = f.Get(context.Background(), FetcherRequest{
+		ID:         "3",
+		URL:        testServer.URL + "/data",
+		Headers:    http.Header{"X-Api-Key": {"own"}},
+		MaxRetries: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "fetcher/1.0 own 42", got.Content)
+}
+
+func TestFetcher_Auth(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	f, err := New(Options{Auth: auth.Bearer{Token: "t0ken"}}, l)
+	require.NoError(t, err)
+
+	got, err := f.Get(context.Background(), FetcherRequest{ID: "1", URL: testServer.URL, MaxRetries: 1})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer t0ken", got.Content)
+
+	// token endpoint is down
+	f, err = New(Options{
+		Auth:  auth.NewClientCredentials(testServer.URL+"/token", "id", "", nil, nil),
+		Retry: RetryPolicy{Errors: []entity.ErrorCategory{}},
+	}, l)
+	require.NoError(t, err)
+
+	got, err = f.Get(context.Background(), FetcherRequest{ID: "2", URL: testServer.URL, MaxRetries: 1})
+	require.ErrorIs(t, err, auth.ErrAuth)
+	require.Equal(t, entity.ErrorCategoryAuth, got.ErrorCategory)
+	require.Len(t, got.Attempts, 1)
+	require.Equal(t, entity.ErrorCategoryAuth, got.Attempts[0].ErrorCategory)
+
+	// token endpoint fails once, the attempt is retried
+	var tokens atomic.Int32
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if tokens.Add(1) == 1 {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"access_token":"fresh","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	f, err = New(Options{Auth: auth.NewClientCredentials(tokenServer.URL, "id", "", nil, nil)}, l)
+	require.NoError(t, err)
+
+	got, err = f.Get(context.Background(), FetcherRequest{ID: "3", URL: testServer.URL, MaxRetries: 2, Method: http.MethodPost})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer fresh", got.Content)
+	require.Len(t, got.Attempts, 2)
+	require.Equal(t, entity.ErrorCategoryAuth, got.Attempts[0].ErrorCategory)
+	require.Empty(t, got.Attempts[1].ErrorCategory)
+}
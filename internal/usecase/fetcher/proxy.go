@@ -0,0 +1,101 @@
+package fetcher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/antonmisa/cliurlfetcher/pkg/hostmatch"
+)
+
+// ProxyDirect in a rule sends requests of the matching hosts without proxy.
+const ProxyDirect = "direct"
+
+var ErrBadProxy = errors.New("bad proxy")
+
+// ProxyRule routes requests of the hosts matching the pattern, see hostmatch.Match.
+// Proxy is an url http://, https:// or socks5:// with optional user:password, or ProxyDirect.
+type ProxyRule struct {
+	Pattern string
+	Proxy   string
+}
+
+// proxyFunc returns proxy of the request, nil means direct connection.
+type proxyFunc func(req *http.Request) (*url.URL, error)
+
+type proxyRoute struct {
+	pattern string
+
+	// url is nil for direct
+	url *url.URL
+}
+
+// newProxyFunc returns the first matching rule proxy, the environment
+// settings are used for the hosts not matching any rule.
+func newProxyFunc(rules []ProxyRule) (proxyFunc, error) {
+	if len(rules) == 0 {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	routes := make([]proxyRoute, 0, len(rules))
+
+	for _, r := range rules {
+		if err := hostmatch.Validate(r.Pattern); err != nil {
+			return nil, err
+		}
+
+		route := proxyRoute{pattern: r.Pattern}
+
+		if !strings.EqualFold(r.Proxy, ProxyDirect) {
+			u, err := parseProxy(r.Proxy)
+			if err != nil {
+				return nil, err
+			}
+
+			route.url = u
+		}
+
+		routes = append(routes, route)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		for _, r := range routes {
+			if hostmatch.Match(r.pattern, req.URL.Hostname()) {
+				return r.url, nil
+			}
+		}
+
+		return http.ProxyFromEnvironment(req)
+	}, nil
+}
+
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadProxy, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme of %s", ErrBadProxy, u.Redacted())
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: no host in %s", ErrBadProxy, u.Redacted())
+	}
+
+	return u, nil
+}
+
+// proxyOf returns the proxy used for the request without password, empty for direct.
+func (f Fetcher) proxyOf(req *http.Request) string {
+	u, err := f.proxy(req)
+	if err != nil || u == nil {
+		return ""
+	}
+
+	return u.Redacted()
+}
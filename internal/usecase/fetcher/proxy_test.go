@@ -0,0 +1,196 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/antonmisa/cliurlfetcher/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// forwardProxy is http proxy checking Proxy-Authorization, it answers with X-Proxy header.
+func forwardProxy(t *testing.T, user, password string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var proxied atomic.Int32
+
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Proxy-Authorization") != want {
+			res.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+
+		proxied.Add(1)
+
+		out := req.Clone(req.Context())
+		out.RequestURI = ""
+		out.Header.Del("Proxy-Authorization")
+
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if err != nil {
+			res.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		res.Header().Set("X-Proxy", "forward")
+		res.WriteHeader(resp.StatusCode)
+		io.Copy(res, resp.Body)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &proxied
+}
+
+// socks5Proxy serves CONNECT of socks5 without auth.
+func socks5Proxy(t *testing.T) (net.Listener, *atomic.Int32) {
+	t.Helper()
+
+	var proxied atomic.Int32
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				// greeting: version, methods count, methods
+				buf := make([]byte, 262)
+				if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+					return
+				}
+
+				if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+					return
+				}
+
+				conn.Write([]byte{5, 0})
+
+				// request: version, connect, reserved, ipv4 address and port
+				if _, err := io.ReadFull(conn, buf[:4]); err != nil || buf[3] != 1 {
+					return
+				}
+
+				if _, err := io.ReadFull(conn, buf[:6]); err != nil {
+					return
+				}
+
+				addr := net.JoinHostPort(net.IP(buf[:4]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(buf[4:6]))))
+
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+
+				proxied.Add(1)
+
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+
+	return ln, &proxied
+}
+
+func TestFetcher_Proxy(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte("ok"))
+	}))
+	defer testServer.Close()
+
+	httpProxy, httpProxied := forwardProxy(t, "user", "secret")
+	socksProxy, socksProxied := socks5Proxy(t)
+
+	l, _ := logger.NewFake()
+
+	// the test server is 127.0.0.1, localhost is the same server routed directly
+	f, err := New(Options{
+		Proxies: []ProxyRule{
+			{Pattern: "localhost", Proxy: ProxyDirect},
+			{Pattern: "*", Proxy: "http://user:secret@" + httpProxy.Listener.Addr().String()},
+		},
+	}, l)
+	require.NoError(t, err)
+
+	got, err := f.Get(context.Background(), FetcherRequest{ID: "1", URL: testServer.URL, MaxRetries: 1})
+	require.NoError(t, err)
+	require.Equal(t, "ok", got.Content)
+	require.Equal(t, "http://user:xxxxx@"+httpProxy.Listener.Addr().String(), got.Proxy)
+	require.Equal(t, int32(1), httpProxied.Load())
+
+	_, port, _ := net.SplitHostPort(testServer.Listener.Addr().String())
+
+	got, err = f.Get(context.Background(), FetcherRequest{ID: "2", URL: "http://localhost:" + port, MaxRetries: 1})
+	require.NoError(t, err)
+	require.Equal(t, "ok", got.Content)
+	require.Empty(t, got.Proxy)
+	require.Equal(t, int32(1), httpProxied.Load())
+
+	// wrong password
+	f, err = New(Options{
+		Proxies: []ProxyRule{{Pattern: "*", Proxy: "http://user:wrong@" + httpProxy.Listener.Addr().String()}},
+	}, l)
+	require.NoError(t, err)
+
+	got, _ = f.Get(context.Background(), FetcherRequest{ID: "3", URL: testServer.URL, MaxRetries: 1})
+	require.Equal(t, http.StatusProxyAuthRequired, got.StatusCode)
+
+	f, err = New(Options{
+		Proxies: []ProxyRule{{Pattern: "*", Proxy: "socks5://" + socksProxy.Addr().String()}},
+	}, l)
+	require.NoError(t, err)
+
+	got, err = f.Get(context.Background(), FetcherRequest{ID: "4", URL: testServer.URL, MaxRetries: 1})
+	require.NoError(t, err)
+	require.Equal(t, "ok", got.Content)
+	require.Equal(t, "socks5://"+socksProxy.Addr().String(), got.Proxy)
+	require.Equal(t, int32(1), socksProxied.Load())
+
+	// clients of other requests, e.g. auth tokens, are routed the same way
+	transport, err := NewTransport([]ProxyRule{{Pattern: "*", Proxy: "socks5://" + socksProxy.Addr().String()}}, TLSOptions{})
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: transport}).Get(testServer.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, int32(2), socksProxied.Load())
+}
+
+func TestNewProxyFunc(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range []string{"ftp://proxy:21", "http://", "proxy:3128"} {
+		_, err := newProxyFunc([]ProxyRule{{Pattern: "*", Proxy: p}})
+		require.ErrorIs(t, err, ErrBadProxy, p)
+	}
+
+	_, err := newProxyFunc([]ProxyRule{{Pattern: "a.*.com", Proxy: ProxyDirect}})
+	require.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+const defaultMaxRedirects = 10
+
+// RedirectOptions of the client, zero options follow up to 10 redirects
+// like the default policy of http.Client.
+type RedirectOptions struct {
+	// NoFollow returns redirect responses as is
+	NoFollow bool
+
+	// MaxHops followed, zero means default
+	MaxHops int
+
+	// SameHost stops at a redirect to another host, its response is returned
+	SameHost bool
+
+	// StripAuth drops Authorization and Cookie headers on a redirect to another host,
+	// http.Client itself drops them only for hosts not under the original domain
+	StripAuth bool
+}
+
+// redirectPolicy is CheckRedirect of the client, hops are recorded to the chain of the request context.
+type redirectPolicy struct {
+	opts RedirectOptions
+}
+
+func newRedirectPolicy(opts RedirectOptions) (redirectPolicy, error) {
+	if opts.MaxHops < 0 {
+		return redirectPolicy{}, fmt.Errorf("negative max redirects %d", opts.MaxHops)
+	}
+
+	if opts.MaxHops == 0 {
+		opts.MaxHops = defaultMaxRedirects
+	}
+
+	return redirectPolicy{opts: opts}, nil
+}
+
+func (p redirectPolicy) check(req *http.Request, via []*http.Request) error {
+	prev := via[len(via)-1]
+
+	if chain, ok := req.Context().Value(redirectChainKey{}).(*redirectChain); ok && req.Response != nil {
+		chain.add(entity.Redirect{
+			URL:        prev.URL.String(),
+			StatusCode: req.Response.StatusCode,
+			Location:   req.URL.String(),
+		})
+	}
+
+	crossHost := !strings.EqualFold(req.URL.Hostname(), prev.URL.Hostname())
+
+	switch {
+	case p.opts.NoFollow, p.opts.SameHost && crossHost:
+		return http.ErrUseLastResponse
+	case len(via) > p.opts.MaxHops:
+		return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, p.opts.MaxHops)
+	}
+
+	if p.opts.StripAuth && crossHost {
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+	}
+
+	return nil
+}
+
+type redirectChainKey struct{}
+
+// redirectChain collects redirects of a single attempt.
+type redirectChain struct {
+	mu   sync.Mutex
+	hops []entity.Redirect
+}
+
+func withRedirectChain(ctx context.Context) (context.Context, *redirectChain) {
+	chain := &redirectChain{}
+
+	return context.WithValue(ctx, redirectChainKey{}, chain), chain
+}
+
+func (c *redirectChain) add(r entity.Redirect) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hops = append(c.hops, r)
+}
+
+func (c *redirectChain) list() []entity.Redirect {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]entity.Redirect(nil), c.hops...)
+}
@@ -0,0 +1,146 @@
+package fetcher
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcher_Redirects(t *testing.T) {
+	t.Parallel()
+
+	// /start -> /next on the same host -> /final on the subdomain, which keeps
+	// Authorization in the default policy of http.Client
+	var other string
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/start":
+			http.Redirect(res, req, "/next", http.StatusMovedPermanently)
+		case "/next":
+			http.Redirect(res, req, other+"/final", http.StatusFound)
+		case "/loop":
+			http.Redirect(res, req, "/loop", http.StatusFound)
+		default:
+			res.Write([]byte("auth=" + req.Header.Get("Authorization")))
+		}
+	}))
+	defer testServer.Close()
+
+	_, port, _ := net.SplitHostPort(testServer.Listener.Addr().String())
+	other = "http://api.example.test:" + port
+
+	start := "http://example.test:" + port + "/start"
+	next := "http://example.test:" + port + "/next"
+	final := other + "/final"
+
+	tests := []struct {
+		name      string
+		opts      RedirectOptions
+		url       string
+		status    int
+		content   string
+		finalURL  string
+		redirects []entity.Redirect
+		err       error
+	}{
+		{
+			name:     "follow",
+			url:      start,
+			status:   http.StatusOK,
+			content:  "auth=Bearer t",
+			finalURL: final,
+			redirects: []entity.Redirect{
+				{URL: start, StatusCode: http.StatusMovedPermanently, Location: next},
+				{URL: next, StatusCode: http.StatusFound, Location: final},
+			},
+		},
+		{
+			name:     "strip auth",
+			opts:     RedirectOptions{StripAuth: true},
+			url:      start,
+			status:   http.StatusOK,
+			content:  "auth=",
+			finalURL: final,
+			redirects: []entity.Redirect{
+				{URL: start, StatusCode: http.StatusMovedPermanently, Location: next},
+				{URL: next, StatusCode: http.StatusFound, Location: final},
+			},
+		},
+		{
+			name:     "no follow",
+			opts:     RedirectOptions{NoFollow: true},
+			url:      start,
+			status:   http.StatusMovedPermanently,
+			finalURL: start,
+			redirects: []entity.Redirect{
+				{URL: start, StatusCode: http.StatusMovedPermanently, Location: next},
+			},
+		},
+		{
+			name:     "same host",
+			opts:     RedirectOptions{SameHost: true},
+			url:      start,
+			status:   http.StatusFound,
+			finalURL: next,
+			redirects: []entity.Redirect{
+				{URL: start, StatusCode: http.StatusMovedPermanently, Location: next},
+				{URL: next, StatusCode: http.StatusFound, Location: final},
+			},
+		},
+		{
+			name: "max hops",
+			opts: RedirectOptions{MaxHops: 1},
+			url:  start,
+			err:  ErrTooManyRedirects,
+			redirects: []entity.Redirect{
+				{URL: start, StatusCode: http.StatusMovedPermanently, Location: next},
+				{URL: next, StatusCode: http.StatusFound, Location: final},
+			},
+		},
+	}
+
+	l, _ := logger.NewFake()
+
+	for _, tc := range tests {
+		f, err := New(Options{Redirects: tc.opts, Retry: RetryPolicy{Errors: []entity.ErrorCategory{}}}, l)
+		require.NoError(t, err, tc.name)
+
+		// every host resolves to the test server
+		f.client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, testServer.Listener.Addr().String())
+		}
+
+		got, err := f.Get(context.Background(), FetcherRequest{
+			ID:         tc.name,
+			URL:        tc.url,
+			Headers:    http.Header{"Authorization": {"Bearer t"}},
+			MaxRetries: 1,
+		})
+		require.Equal(t, tc.redirects, got.Redirects, tc.name)
+
+		if tc.err != nil {
+			require.ErrorIs(t, err, tc.err, tc.name)
+			require.Equal(t, entity.ErrorCategoryTooManyRedirects, got.ErrorCategory, tc.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.status, got.StatusCode, tc.name)
+		require.Equal(t, tc.finalURL, got.FinalURL, tc.name)
+
+		if tc.content != "" {
+			require.Equal(t, tc.content, got.Content, tc.name)
+		}
+	}
+
+	_, err := New(Options{Redirects: RedirectOptions{MaxHops: -1}}, l)
+	require.Error(t, err)
+}
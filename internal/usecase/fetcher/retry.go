@@ -0,0 +1,225 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+const (
+	defaultRetryWaitMin = 50 * time.Millisecond
+	defaultRetryWaitMax = 5 * time.Second
+)
+
+var (
+	ErrBadStatusRange     = errors.New("bad status range")
+	ErrNotRetryableReason = errors.New("error category could not be retried")
+	ErrRetryAfterTooLong  = errors.New("server asked to retry after too long")
+)
+
+// DefaultRetryStatuses are 429 Too Many Requests and 503 Service Unavailable,
+// the server may put Retry-After header to them.
+var DefaultRetryStatuses = []StatusRange{
+	{From: http.StatusTooManyRequests, To: http.StatusTooManyRequests},
+	{From: http.StatusServiceUnavailable, To: http.StatusServiceUnavailable},
+}
+
+// DefaultRetryErrors are categories of transport errors and failures to get credentials,
+// too many redirects is not among them, a redirect loop repeats on every attempt.
+var DefaultRetryErrors = []entity.ErrorCategory{
+	entity.ErrorCategoryAuth,
+	entity.ErrorCategoryDNS,
+	entity.ErrorCategoryConnectionRefused,
+	entity.ErrorCategoryTLS,
+	entity.ErrorCategoryTimeout,
+	entity.ErrorCategoryOther,
+}
+
+// RetryErrorsNone given to ParseErrorCategories disables retries of transport errors.
+const RetryErrorsNone = "none"
+
+// StatusRange is an inclusive range of status codes.
+type StatusRange struct {
+	From int
+	To   int
+}
+
+func (r StatusRange) contains(code int) bool {
+	return code >= r.From && code <= r.To
+}
+
+// ParseStatusRange parses single code "503", range "500-504" or class "5xx".
+func ParseStatusRange(s string) (StatusRange, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '1' && s[0] <= '5' {
+		from := int(s[0]-'0') * 100
+		return StatusRange{From: from, To: from + 99}, nil
+	}
+
+	fromStr, toStr, isRange := strings.Cut(s, "-")
+	if !isRange {
+		toStr = fromStr
+	}
+
+	from, errFrom := strconv.Atoi(strings.TrimSpace(fromStr))
+	to, errTo := strconv.Atoi(strings.TrimSpace(toStr))
+
+	if errFrom != nil || errTo != nil || from < 100 || to > 599 || from > to {
+		return StatusRange{}, fmt.Errorf("%w: %q", ErrBadStatusRange, s)
+	}
+
+	return StatusRange{From: from, To: to}, nil
+}
+
+// ParseStatusRanges parses every range, see ParseStatusRange.
+func ParseStatusRanges(specs []string) ([]StatusRange, error) {
+	res := make([]StatusRange, 0, len(specs))
+
+	for _, s := range specs {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+
+		r, err := ParseStatusRange(s)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, r)
+	}
+
+	return res, nil
+}
+
+// ParseErrorCategories parses categories of transport errors to retry on,
+// no names mean nil, i.e. DefaultRetryErrors, and RetryErrorsNone means no categories.
+func ParseErrorCategories(names []string) ([]entity.ErrorCategory, error) {
+	var res []entity.ErrorCategory
+
+	for _, name := range names {
+		switch c := entity.ErrorCategory(strings.ToLower(strings.TrimSpace(name))); c {
+		case "":
+			continue
+		case RetryErrorsNone:
+			res = []entity.ErrorCategory{}
+		case entity.ErrorCategoryAuth, entity.ErrorCategoryDNS, entity.ErrorCategoryConnectionRefused, entity.ErrorCategoryTLS,
+			entity.ErrorCategoryTimeout, entity.ErrorCategoryTooManyRedirects, entity.ErrorCategoryOther:
+			res = append(res, c)
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrNotRetryableReason, name)
+		}
+	}
+
+	return res, nil
+}
+
+// RetryPolicy tells which outcomes of the request are worth another attempt.
+type RetryPolicy struct {
+	// Statuses are retryable status codes, nil means DefaultRetryStatuses
+	Statuses []StatusRange
+
+	// Errors are retryable categories of transport errors, nil means DefaultRetryErrors
+	Errors []entity.ErrorCategory
+
+	// NonIdempotent allows to retry POST, PATCH and others
+	// not having Idempotency-Key header
+	NonIdempotent bool
+
+	// WaitMin and WaitMax bound the backoff, zero means default
+	WaitMin time.Duration
+	WaitMax time.Duration
+
+	// MaxRetryAfter is the longest honored wait requested by Retry-After header,
+	// zero means no limit. Longer waits are cut to it or, if AbandonLongRetryAfter
+	// is set, the request is given up.
+	MaxRetryAfter         time.Duration
+	AbandonLongRetryAfter bool
+}
+
+// CheckRetry is the CheckRetry callback of the policy.
+func (p RetryPolicy) CheckRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	// do not retry on context.Canceled or context.DeadlineExceeded
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if err != nil {
+		category := Classify(err, resp)
+
+		errs := p.Errors
+		if errs == nil {
+			errs = DefaultRetryErrors
+		}
+
+		for _, c := range errs {
+			if c == category {
+				return true, nil
+			}
+		}
+
+		return false, err
+	}
+
+	statuses := p.Statuses
+	if statuses == nil {
+		statuses = DefaultRetryStatuses
+	}
+
+	for _, r := range statuses {
+		if r.contains(resp.StatusCode) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// canRetry reports whether the request may be sent again by the policy.
+func (p RetryPolicy) canRetry(req *http.Request) bool {
+	if p.NonIdempotent {
+		return true
+	}
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	// the same as http.Transport does, the server deduplicates requests by the key
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// waits returns backoff bounds of the request, falling back to the policy and defaults.
+func (p RetryPolicy) waits(req FetcherRequest) (time.Duration, time.Duration) {
+	waitMin, waitMax := req.RetryWaitMin, req.RetryWaitMax
+
+	if waitMin <= 0 {
+		waitMin = p.WaitMin
+	}
+
+	if waitMin <= 0 {
+		waitMin = defaultRetryWaitMin
+	}
+
+	if waitMax <= 0 {
+		waitMax = p.WaitMax
+	}
+
+	if waitMax <= 0 {
+		waitMax = defaultRetryWaitMax
+	}
+
+	if waitMax < waitMin {
+		waitMax = waitMin
+	}
+
+	return waitMin, waitMax
+}
@@ -0,0 +1,163 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+// Phases of the attempt bounded by timeouts.
+const (
+	phaseTotal          = "total"
+	phaseConnect        = "connect"
+	phaseTLSHandshake   = "tls handshake"
+	phaseResponseHeader = "response header"
+	phaseBodyIdle       = "body idle"
+)
+
+var ErrTimeout = errors.New("timeout")
+
+// mergeTimeouts returns the defaults overridden by the non-zero timeouts of the task.
+func mergeTimeouts(def, task entity.Timeouts) entity.Timeouts {
+	res := def
+
+	if task.Connect > 0 {
+		res.Connect = task.Connect
+	}
+
+	if task.TLSHandshake > 0 {
+		res.TLSHandshake = task.TLSHandshake
+	}
+
+	if task.ResponseHeader > 0 {
+		res.ResponseHeader = task.ResponseHeader
+	}
+
+	if task.BodyIdle > 0 {
+		res.BodyIdle = task.BodyIdle
+	}
+
+	return res
+}
+
+// deadlines cancels the attempt when a phase takes longer than its timeout,
+// the cause of the cancellation tells the phase.
+type deadlines struct {
+	mu       sync.Mutex
+	timeouts entity.Timeouts
+	cancel   context.CancelCauseFunc
+	timers   map[string]*time.Timer
+}
+
+// withDeadlines returns ctx bounded by the total timeout and the phase timeouts,
+// the returned func releases them and must be called when the attempt is over.
+func withDeadlines(ctx context.Context, total time.Duration, timeouts entity.Timeouts) (context.Context, *deadlines, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	d := &deadlines{
+		timeouts: timeouts,
+		cancel:   cancel,
+		timers:   map[string]*time.Timer{},
+	}
+
+	d.start(phaseTotal, total)
+
+	// the connect timeout bounds dialing of all addresses, a failed one does not stop it
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { d.start(phaseConnect, d.timeouts.Connect) },
+		ConnectStart: func(string, string) { d.start(phaseConnect, d.timeouts.Connect) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				d.stop(phaseConnect)
+			}
+		},
+		TLSHandshakeStart:    func() { d.start(phaseTLSHandshake, d.timeouts.TLSHandshake) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { d.stop(phaseTLSHandshake) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { d.start(phaseResponseHeader, d.timeouts.ResponseHeader) },
+		GotFirstResponseByte: func() { d.stop(phaseResponseHeader) },
+	})
+
+	return ctx, d, func() {
+		d.stopAll()
+		cancel(nil)
+	}
+}
+
+// start the timer of the phase, a running one is kept, e.g. dialing of several addresses.
+func (d *deadlines) start(phase string, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.timers[phase]; ok {
+		return
+	}
+
+	d.timers[phase] = time.AfterFunc(timeout, func() {
+		d.cancel(fmt.Errorf("%s %w after %s", phase, ErrTimeout, timeout))
+	})
+}
+
+func (d *deadlines) stop(phase string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t, ok := d.timers[phase]; ok {
+		t.Stop()
+		delete(d.timers, phase)
+	}
+}
+
+func (d *deadlines) stopAll() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for phase, t := range d.timers {
+		t.Stop()
+		delete(d.timers, phase)
+	}
+}
+
+// body bounds waiting for every read of the body by the idle timeout.
+func (d *deadlines) body(body io.ReadCloser) io.ReadCloser {
+	if d.timeouts.BodyIdle <= 0 {
+		return body
+	}
+
+	return &idleBody{ReadCloser: body, deadlines: d}
+}
+
+type idleBody struct {
+	io.ReadCloser
+	deadlines *deadlines
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	b.deadlines.start(phaseBodyIdle, b.deadlines.timeouts.BodyIdle)
+	defer b.deadlines.stop(phaseBodyIdle)
+
+	return b.ReadCloser.Read(p)
+}
+
+// timeoutCause returns err wrapped by the timeout which canceled ctx, err as is otherwise.
+func timeoutCause(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if cause := context.Cause(ctx); errors.Is(cause, ErrTimeout) && !errors.Is(err, ErrTimeout) {
+		return fmt.Errorf("%w: %w", cause, err)
+	}
+
+	return err
+}
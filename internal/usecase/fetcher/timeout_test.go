@@ -0,0 +1,196 @@
+package fetcher
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetcher_Timeouts(t *testing.T) {
+	t.Parallel()
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		case "/stall":
+			res.Write([]byte("first chunk"))
+			res.(http.Flusher).Flush()
+
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		res.Write([]byte("done"))
+	}))
+	defer testServer.Close()
+
+	// accepts connections and never answers, so tls handshake hangs
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	defer silent.Close()
+
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+
+			defer conn.Close()
+		}
+	}()
+
+	// dialing never completes, as to a host dropping packets
+	hang := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		trace := httptrace.ContextClientTrace(ctx)
+		trace.ConnectStart(network, addr)
+
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	// the first address fails and the second connects, each of them within the timeout, not both
+	fallback := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		trace := httptrace.ContextClientTrace(ctx)
+
+		trace.ConnectStart(network, "[::1]:1")
+		time.Sleep(45 * time.Millisecond)
+		trace.ConnectDone(network, "[::1]:1", syscall.ECONNREFUSED)
+
+		trace.ConnectStart(network, addr)
+		time.Sleep(45 * time.Millisecond)
+
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+		trace.ConnectDone(network, addr, err)
+
+		return conn, err
+	}
+
+	tests := []struct {
+		name    string
+		opts    Options
+		req     FetcherRequest
+		dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "total",
+			opts:    Options{Timeout: 20 * time.Millisecond},
+			req:     FetcherRequest{URL: testServer.URL + "/slow"},
+			wantErr: "total timeout after 20ms",
+		},
+		{
+			name:    "total of the task",
+			opts:    Options{Timeout: time.Second},
+			req:     FetcherRequest{URL: testServer.URL + "/slow", Timeout: 20 * time.Millisecond},
+			wantErr: "total timeout after 20ms",
+		},
+		{
+			name:    "connect",
+			opts:    Options{Timeouts: entity.Timeouts{Connect: 20 * time.Millisecond}},
+			req:     FetcherRequest{URL: testServer.URL},
+			dial:    hang,
+			wantErr: "connect timeout after 20ms",
+		},
+		{
+			name:    "connect of all addresses",
+			opts:    Options{Timeouts: entity.Timeouts{Connect: 60 * time.Millisecond}},
+			req:     FetcherRequest{URL: testServer.URL},
+			dial:    fallback,
+			wantErr: "connect timeout after 60ms",
+		},
+		{
+			name:    "tls handshake",
+			opts:    Options{Timeouts: entity.Timeouts{TLSHandshake: 20 * time.Millisecond}},
+			req:     FetcherRequest{URL: "https://" + silent.Addr().String()},
+			wantErr: "tls handshake timeout after 20ms",
+		},
+		{
+			name:    "response header",
+			opts:    Options{Timeouts: entity.Timeouts{ResponseHeader: 20 * time.Millisecond}},
+			req:     FetcherRequest{URL: testServer.URL + "/slow"},
+			wantErr: "response header timeout after 20ms",
+		},
+		{
+			name: "response header of the task",
+			opts: Options{Timeouts: entity.Timeouts{ResponseHeader: 20 * time.Millisecond}},
+			req: FetcherRequest{
+				URL:      testServer.URL + "/slow",
+				Timeouts: entity.Timeouts{ResponseHeader: time.Second},
+			},
+			want: "done",
+		},
+		{
+			name:    "body idle",
+			opts:    Options{Timeouts: entity.Timeouts{BodyIdle: 20 * time.Millisecond}},
+			req:     FetcherRequest{URL: testServer.URL + "/stall"},
+			wantErr: "body idle timeout after 20ms",
+		},
+		{
+			name: "body within idle timeout",
+			opts: Options{Timeouts: entity.Timeouts{BodyIdle: time.Second}},
+			req:  FetcherRequest{URL: testServer.URL + "/stall", ReadLimit: 100},
+			want: "first chunkdone",
+		},
+	}
+
+	l, _ := logger.NewFake()
+
+	for _, tc := range tests {
+		// the single attempt keeps the category of its error
+		tc.opts.Retry.Errors = []entity.ErrorCategory{}
+
+		f, err := New(tc.opts, l)
+		require.NoError(t, err, tc.name)
+
+		if tc.dial != nil {
+			f.client.Transport.(*http.Transport).DialContext = tc.dial
+		}
+
+		tc.req.ID, tc.req.MaxRetries = tc.name, 1
+
+		started := time.Now()
+
+		got, err := f.Get(context.Background(), tc.req)
+		if tc.wantErr != "" {
+			require.Error(t, err, tc.name)
+			require.ErrorIs(t, err, ErrTimeout, tc.name)
+			require.Equal(t, entity.ErrorCategoryTimeout, got.ErrorCategory, tc.name)
+			require.Contains(t, got.Error, tc.wantErr, tc.name)
+			require.Equal(t, entity.ErrorCategoryTimeout, got.Attempts[0].ErrorCategory, tc.name)
+			require.Less(t, time.Since(started), 150*time.Millisecond, tc.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.want, got.Content, tc.name)
+	}
+
+	_, err = New(Options{Timeouts: entity.Timeouts{BodyIdle: -time.Second}}, l)
+	require.Error(t, err)
+}
+
+func TestMergeTimeouts(t *testing.T) {
+	t.Parallel()
+
+	def := entity.Timeouts{Connect: time.Second, TLSHandshake: time.Second}
+
+	require.Equal(t, def, mergeTimeouts(def, entity.Timeouts{}))
+	require.Equal(t,
+		entity.Timeouts{Connect: time.Second, TLSHandshake: time.Minute, BodyIdle: time.Millisecond},
+		mergeTimeouts(def, entity.Timeouts{TLSHandshake: time.Minute, BodyIdle: time.Millisecond}),
+	)
+}
@@ -0,0 +1,241 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/antonmisa/cliurlfetcher/pkg/hostmatch"
+)
+
+var ErrBadTLS = errors.New("bad tls config")
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig of connections, zero config means system defaults.
+type TLSConfig struct {
+	// CAFiles are PEM bundles trusted in addition to the system roots
+	CAFiles []string
+
+	// CertFile and KeyFile are PEM client certificate and its key for mTLS
+	CertFile string
+	KeyFile  string
+
+	// MinVersion is 1.0, 1.1, 1.2 or 1.3, empty means the Go default
+	MinVersion string
+
+	// ServerName overrides SNI and the name the certificate is verified against
+	ServerName string
+
+	// Insecure skips verification of the server certificate
+	Insecure bool
+}
+
+// TLSRule overrides the default config for the hosts matching the pattern, see hostmatch.Match.
+// CA files are added to the default ones, other fields replace the default when set.
+type TLSRule struct {
+	Pattern string
+
+	CAFiles    []string
+	CertFile   string
+	KeyFile    string
+	MinVersion string
+	ServerName string
+
+	// Insecure skips verification when true and enforces it when false, nil keeps the default
+	Insecure *bool
+}
+
+// TLSOptions are the default config and rules checked in order, the first matching wins.
+type TLSOptions struct {
+	TLSConfig
+	Rules []TLSRule
+}
+
+func (o TLSOptions) configured() bool {
+	c := o.TLSConfig
+
+	return len(o.Rules) > 0 || len(c.CAFiles) > 0 || c.CertFile != "" || c.KeyFile != "" ||
+		c.MinVersion != "" || c.ServerName != "" || c.Insecure
+}
+
+// tlsRouter sends requests through the transport of the first rule matching the host.
+type tlsRouter struct {
+	routes   []tlsRoute
+	fallback *http.Transport
+}
+
+type tlsRoute struct {
+	pattern   string
+	transport *http.Transport
+}
+
+func (r *tlsRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, route := range r.routes {
+		if hostmatch.Match(route.pattern, req.URL.Hostname()) {
+			return route.transport.RoundTrip(req)
+		}
+	}
+
+	return r.fallback.RoundTrip(req)
+}
+
+// CloseIdleConnections of all the transports, it is called by http.Client.
+func (r *tlsRouter) CloseIdleConnections() {
+	for _, route := range r.routes {
+		route.transport.CloseIdleConnections()
+	}
+
+	r.fallback.CloseIdleConnections()
+}
+
+// newTLSTransport returns base transport with the default config, or the router
+// of transports cloned from it when there are rules.
+func newTLSTransport(base *http.Transport, opts TLSOptions) (http.RoundTripper, error) {
+	cfg, err := newTLSClientConfig(opts.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	base.TLSClientConfig = cfg
+
+	if len(opts.Rules) == 0 {
+		return base, nil
+	}
+
+	router := &tlsRouter{fallback: base}
+
+	for _, r := range opts.Rules {
+		if err := hostmatch.Validate(r.Pattern); err != nil {
+			return nil, err
+		}
+
+		cfg, err := newTLSClientConfig(mergeTLSConfig(opts.TLSConfig, r))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Pattern, err)
+		}
+
+		t := base.Clone()
+		t.TLSClientConfig = cfg
+
+		router.routes = append(router.routes, tlsRoute{pattern: r.Pattern, transport: t})
+	}
+
+	return router, nil
+}
+
+func mergeTLSConfig(def TLSConfig, rule TLSRule) TLSConfig {
+	res := def
+
+	res.CAFiles = append(append([]string(nil), def.CAFiles...), rule.CAFiles...)
+
+	if rule.CertFile != "" || rule.KeyFile != "" {
+		res.CertFile, res.KeyFile = rule.CertFile, rule.KeyFile
+	}
+
+	if rule.MinVersion != "" {
+		res.MinVersion = rule.MinVersion
+	}
+
+	if rule.ServerName != "" {
+		res.ServerName = rule.ServerName
+	}
+
+	if rule.Insecure != nil {
+		res.Insecure = *rule.Insecure
+	}
+
+	return res
+}
+
+func newTLSClientConfig(c TLSConfig) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.Insecure, //nolint:gosec // enabled by insecure option only
+	}
+
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown tls version %q", ErrBadTLS, c.MinVersion)
+		}
+
+		cfg.MinVersion = v
+	}
+
+	if len(c.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, path := range c.CAFiles {
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrBadTLS, err)
+			}
+
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("%w: no certificates in %s", ErrBadTLS, path)
+			}
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: client certificate: %w", ErrBadTLS, err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// tlsReason returns why the tls connection failed, empty if unknown.
+func tlsReason(err error) string {
+	var (
+		authErr   x509.UnknownAuthorityError
+		hostErr   x509.HostnameError
+		certErr   x509.CertificateInvalidError
+		verifyErr *tls.CertificateVerificationError
+		recordErr tls.RecordHeaderError
+	)
+
+	switch {
+	case errors.As(err, &authErr):
+		return "certificate signed by unknown authority"
+	case errors.As(err, &hostErr):
+		return "certificate is not valid for " + hostErr.Host
+	case errors.As(err, &certErr):
+		if certErr.Reason == x509.Expired {
+			return "certificate is expired or not yet valid"
+		}
+
+		return "invalid certificate"
+	case errors.As(err, &verifyErr):
+		return "certificate verification failed"
+	case errors.As(err, &recordErr):
+		return "server does not speak tls"
+	}
+
+	// alerts sent by the server, e.g. client certificate required
+	msg := err.Error()
+	if i := strings.Index(msg, "remote error: "); i >= 0 {
+		return "rejected by server: " + strings.TrimPrefix(msg[i+len("remote error: "):], "tls: ")
+	}
+
+	return ""
+}
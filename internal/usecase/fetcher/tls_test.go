@@ -0,0 +1,309 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA issues certificates, PEM files are written to dir.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	ca := &testCA{dir: t.TempDir()}
+
+	ca.cert, ca.key = ca.issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	})
+
+	return ca
+}
+
+// issue signs the template by the CA, self-signed when the CA is not ready yet.
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parent, signer := tmpl, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+// files writes the certificate and the key, their paths are returned.
+func (ca *testCA) files(t *testing.T, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (string, string) {
+	t.Helper()
+
+	certPath := filepath.Join(ca.dir, name+".crt")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600))
+
+	if key == nil {
+		return certPath, ""
+	}
+
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(ca.dir, name+".key")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+
+	return certPath, keyPath
+}
+
+func (ca *testCA) tlsCert(t *testing.T, tmpl *x509.Certificate) tls.Certificate {
+	t.Helper()
+
+	cert, key := ca.issue(t, tmpl)
+
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+}
+
+func TestFetcher_TLS(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t)
+	caFile, _ := ca.files(t, "ca", ca.cert, nil)
+
+	clientCert, clientKey := ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "fetcher"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	certFile, keyFile := ca.files(t, "client", clientCert, clientKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	// the server of a private CA requiring client certificate
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName + " " + req.TLS.ServerName))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.tlsCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "internal.test"},
+			DNSNames:    []string{"internal.test"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	insecure, secure := true, false
+
+	tests := []struct {
+		name    string
+		opts    TLSOptions
+		url     string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "unknown authority",
+			url:     srv.URL,
+			wantErr: "certificate signed by unknown authority: ",
+		},
+		{
+			name:    "no client certificate",
+			opts:    TLSOptions{TLSConfig: TLSConfig{CAFiles: []string{caFile}}},
+			url:     srv.URL,
+			wantErr: "rejected by server: ",
+		},
+		{
+			name: "client certificate of the host",
+			opts: TLSOptions{
+				TLSConfig: TLSConfig{CAFiles: []string{caFile}},
+				Rules: []TLSRule{
+					{Pattern: "127.0.0.1", CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"},
+				},
+			},
+			url:  srv.URL,
+			want: "fetcher ",
+		},
+		{
+			name: "sni override",
+			opts: TLSOptions{
+				TLSConfig: TLSConfig{CAFiles: []string{caFile}, CertFile: certFile, KeyFile: keyFile},
+				Rules: []TLSRule{
+					{Pattern: "localhost", ServerName: "internal.test"},
+				},
+			},
+			url:  "https://localhost:" + port,
+			want: "fetcher internal.test",
+		},
+		{
+			name: "sni not in certificate",
+			opts: TLSOptions{
+				TLSConfig: TLSConfig{CAFiles: []string{caFile}, CertFile: certFile, KeyFile: keyFile, ServerName: "other.test"},
+			},
+			url:     srv.URL,
+			wantErr: "certificate is not valid for other.test: ",
+		},
+		{
+			name: "insecure",
+			opts: TLSOptions{TLSConfig: TLSConfig{Insecure: true, CertFile: certFile, KeyFile: keyFile}},
+			url:  srv.URL,
+			want: "fetcher ",
+		},
+		{
+			name: "insecure of the host",
+			opts: TLSOptions{
+				TLSConfig: TLSConfig{CertFile: certFile, KeyFile: keyFile},
+				Rules:     []TLSRule{{Pattern: "127.0.0.1", Insecure: &insecure}},
+			},
+			url:  srv.URL,
+			want: "fetcher ",
+		},
+		{
+			name: "verified host of insecure default",
+			opts: TLSOptions{
+				TLSConfig: TLSConfig{Insecure: true, CertFile: certFile, KeyFile: keyFile},
+				Rules:     []TLSRule{{Pattern: "127.0.0.1", Insecure: &secure}},
+			},
+			url:     srv.URL,
+			wantErr: "certificate signed by unknown authority: ",
+		},
+	}
+
+	l, _ := logger.NewFake()
+
+	for _, tc := range tests {
+		// the single attempt keeps the category of its error
+		f, err := New(Options{TLS: tc.opts, Retry: RetryPolicy{Errors: []entity.ErrorCategory{}}}, l)
+		require.NoError(t, err, tc.name)
+
+		got, err := f.Get(context.Background(), FetcherRequest{ID: tc.name, URL: tc.url, MaxRetries: 1})
+		if tc.wantErr != "" {
+			require.Error(t, err, tc.name)
+			require.Equal(t, entity.ErrorCategoryTLS, got.ErrorCategory, tc.name)
+			require.Contains(t, got.Error, tc.wantErr, tc.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tc.name)
+		require.Equal(t, tc.want, got.Content, tc.name)
+	}
+}
+
+func TestNewTLSClientConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := newTLSClientConfig(TLSConfig{MinVersion: "1.4"})
+	require.ErrorIs(t, err, ErrBadTLS)
+
+	_, err = newTLSClientConfig(TLSConfig{CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}})
+	require.ErrorIs(t, err, ErrBadTLS)
+
+	_, err = newTLSClientConfig(TLSConfig{CertFile: "client.crt"})
+	require.ErrorIs(t, err, ErrBadTLS)
+}
+
+func TestFetcher_CertInfo(t *testing.T) {
+	t.Parallel()
+
+	ca := newTestCA(t)
+	caFile, _ := ca.files(t, "ca", ca.cert, nil)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{ca.tlsCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "internal.test"},
+			DNSNames:    []string{"internal.test"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		})},
+		MaxVersion: tls.VersionTLS12,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	l, _ := logger.NewFake()
+
+	for _, warnDays := range []int{0, 30} {
+		f, err := New(Options{TLS: TLSOptions{TLSConfig: TLSConfig{CAFiles: []string{caFile}}}, CertWarnDays: warnDays}, l)
+		require.NoError(t, err)
+
+		got, err := f.Get(context.Background(), FetcherRequest{ID: "1", URL: srv.URL, MaxRetries: 1})
+		require.NoError(t, err)
+
+		require.Equal(t, "TLS 1.2", got.TLS.Version)
+		require.NotEmpty(t, got.TLS.CipherSuite)
+		require.Equal(t, warnDays > 0, got.TLS.ExpiresSoon)
+		require.Len(t, got.TLS.Chain, 1)
+
+		leaf := got.TLS.Chain[0]
+		require.Equal(t, "CN=internal.test", leaf.Subject)
+		require.Equal(t, "CN=test ca", leaf.Issuer)
+		require.Equal(t, []string{"internal.test", "127.0.0.1"}, leaf.SANs)
+		require.Zero(t, leaf.DaysLeft)
+	}
+
+	// plain http has no tls info
+	plain := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	defer plain.Close()
+
+	got, err := Constructor(l).Get(context.Background(), FetcherRequest{ID: "2", URL: plain.URL, MaxRetries: 1})
+	require.NoError(t, err)
+	require.Empty(t, got.TLS.Version)
+}
+
+func TestDaysLeft(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, 0, daysLeft(now.Add(23*time.Hour), now))
+	require.Equal(t, 30, daysLeft(now.Add(30*day+time.Minute), now))
+	require.Equal(t, -1, daysLeft(now.Add(-time.Minute), now))
+	require.Equal(t, -2, daysLeft(now.Add(-day-time.Minute), now))
+}
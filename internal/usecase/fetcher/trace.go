@@ -0,0 +1,108 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+// tracer collects timings of a single attempt, hooks of httptrace could be
+// called from different goroutines. Phases are of the final hop of redirects,
+// the total includes all of them.
+type tracer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	connReused   bool
+}
+
+func newTracer() *tracer {
+	return &tracer{
+		start: time.Now(),
+	}
+}
+
+// set stores now into the field under the lock, keepFirst prevents
+// overwriting when the hook is called several times, e.g. dialing of
+// several addresses.
+func (t *tracer) set(field *time.Time, keepFirst bool) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if keepFirst && !field.IsZero() {
+		return
+	}
+
+	*field = now
+}
+
+// context returns ctx with the tracer attached.
+func (t *tracer) context(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { t.newHop() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.connReused = info.Reused
+			t.mu.Unlock()
+		},
+		DNSStart:             func(httptrace.DNSStartInfo) { t.set(&t.dnsStart, true) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone, false) },
+		ConnectStart:         func(string, string) { t.set(&t.connectStart, true) },
+		ConnectDone:          func(string, string, error) { t.set(&t.connectDone, false) },
+		TLSHandshakeStart:    func() { t.set(&t.tlsStart, true) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.set(&t.tlsDone, false) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest, false) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte, true) },
+	})
+}
+
+// newHop forgets phases of the previous request of redirects.
+func (t *tracer) newHop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+	t.connectStart, t.connectDone = time.Time{}, time.Time{}
+	t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+	t.wroteRequest, t.firstByte = time.Time{}, time.Time{}
+	t.connReused = false
+}
+
+// timing returns phases durations, done is the moment the body was read.
+func (t *tracer) timing(done time.Time) entity.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return entity.Timing{
+		DNSLookup:       between(t.dnsStart, t.dnsDone),
+		Connect:         between(t.connectStart, t.connectDone),
+		TLSHandshake:    between(t.tlsStart, t.tlsDone),
+		TimeToFirstByte: between(t.wroteRequest, t.firstByte),
+		ContentTransfer: between(t.firstByte, done),
+		Total:           between(t.start, done),
+		ConnReused:      t.connReused,
+	}
+}
+
+// between returns zero if any of the moments is unknown.
+func between(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+
+	return to.Sub(from)
+}
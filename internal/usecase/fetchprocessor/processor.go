@@ -3,26 +3,30 @@ package fetchprocessor
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase/fetcher"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/queue"
 	"github.com/antonmisa/cliurlfetcher/pkg/logger"
 )
 
 const (
 	defaultShutdownTimeout time.Duration = 5 * time.Second
 
-	defaultRetryWaitMinTime time.Duration = 50 * time.Millisecond
-	defaultRetryWaitMaxTime time.Duration = 5 * time.Second
-	defaultMaxAttempts      int           = 3
+	// defaultLookahead is number of input tasks waiting for their hosts
+	// in addition to the tasks of workers
+	defaultLookahead = 100
 )
 
+// FetchProcessor makes one attempt of a task at a time. A task to be retried is put
+// to the delay queue till its backoff is over, so workers fetch others meanwhile.
 type FetchProcessor struct {
 	workers         int
+	fetcher         fetcher.Fetcher
 	logger          logger.Interface
 	in              usecase.QueueReader
 	out             usecase.QueueWriter
@@ -30,19 +34,36 @@ type FetchProcessor struct {
 	wg              sync.WaitGroup
 	shutdown        atomic.Bool
 	shutdownTimeout time.Duration
+
+	// limiter keeps hosts from overload, nil means no limits
+	limiter usecase.Limiter
+
+	// delayed holds input and retried tasks, fresh bounds input tasks not
+	// started yet, they wait there or in the limiter
+	delayed *queue.DelayQueue
+	fresh   chan struct{}
+
+	// pending is number of tasks taken from input and not pushed out yet
+	pending   atomic.Int64
+	inputDone atomic.Bool
 }
 
 var _ usecase.StartStoper = (*FetchProcessor)(nil)
 
-func New(ctx context.Context, workers int, in usecase.QueueReader, out usecase.QueueWriter, l logger.Interface) *FetchProcessor {
+// New returns processor of tasks, lim could be nil if there are no limits.
+func New(ctx context.Context, workers int, f fetcher.Fetcher, lim usecase.Limiter, in usecase.QueueReader, out usecase.QueueWriter, l logger.Interface) *FetchProcessor {
 	fr := &FetchProcessor{
 		ctx:             ctx,
 		workers:         workers,
+		fetcher:         f,
+		limiter:         lim,
 		logger:          l,
 		in:              in,
 		out:             out,
 		shutdown:        atomic.Bool{},
 		shutdownTimeout: defaultShutdownTimeout,
+		delayed:         queue.NewDelayQueue(),
+		fresh:           make(chan struct{}, workers+defaultLookahead),
 	}
 	fr.shutdown.Store(false)
 
@@ -52,6 +73,14 @@ func New(ctx context.Context, workers int, in usecase.QueueReader, out usecase.Q
 func (fr *FetchProcessor) Start() error {
 	op := "FetchProcessor - Start"
 
+	fr.wg.Add(1)
+
+	go func() {
+		defer fr.wg.Done()
+
+		fr.feed()
+	}()
+
 	for i := 1; i <= fr.workers; i++ {
 		fr.wg.Add(1)
 
@@ -60,8 +89,6 @@ func (fr *FetchProcessor) Start() error {
 
 			fr.logger.Info("%s number %d of %d", op, id, fr.workers)
 
-			ftchr := fetcher.Constructor(fr.logger)
-
 			for {
 				if fr.shutdown.Load() {
 					return
@@ -71,53 +98,198 @@ func (fr *FetchProcessor) Start() error {
 				case <-fr.ctx.Done():
 					return
 				default:
-					if task, ok := fr.in.Pop(); ok {
-						if !task.IsReady() {
-							fr.logger.Info("%s number %d received ready task with id %s", op, id, task.ID)
+					if task, ok := fr.delayed.Pop(); ok {
+						fr.run(id, task)
+					} else {
+						fr.logger.Info("%s number %d no more data income", op, id)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+
+	return nil
+}
 
-							err := fr.out.Push(task)
-							if err != nil {
-								fr.logger.Error(" - fr.out.Push: %w", op, err)
-							}
+// feed moves input tasks to the delay queue, the queue is closed
+// when the input is over and all tasks are done.
+func (fr *FetchProcessor) feed() {
+	op := "FetchProcessor - feed"
 
-							continue
-						}
+	// waiting workers are released on interrupt
+	go func() {
+		<-fr.ctx.Done()
+		fr.delayed.Close()
+	}()
 
-						req := fetcher.FetcherRequest{
-							ID:     task.ID,
-							Method: http.MethodGet,
-							URL:    task.InputParams.URL,
+	for {
+		select {
+		case fr.fresh <- struct{}{}:
+		case <-fr.ctx.Done():
+			return
+		}
 
-							RetryWaitMin: defaultRetryWaitMinTime,
-							RetryWaitMax: defaultRetryWaitMaxTime,
-							MaxRetries:   task.CurrentState.MaxRetries,
-						}
+		task, ok := fr.in.Pop()
+		if !ok {
+			break
+		}
 
-						task.OutputParams.TimeStarted = time.Now()
+		fr.pending.Add(1)
 
-						resp, _ := ftchr.Get(fr.ctx, req)
+		task.CurrentState.NotBefore = time.Now()
 
-						task.OutputParams.TimeCompleted = time.Now()
+		if err := fr.delayed.Push(task); err != nil {
+			fr.logger.Error("%s - fr.delayed.Push: %v", op, err)
+			return
+		}
+	}
 
-						task.CurrentState.Retries = resp.Retries
-						task.OutputParams.StatusCode = resp.StatusCode
-						task.OutputParams.Content = resp.Content
-						task.OutputParams.ContentLength = resp.ContentLength
+	fr.inputDone.Store(true)
 
-						err := fr.out.Push(task)
-						if err != nil {
-							fr.logger.Error(" - fr.out.Push: %w", op, err)
-						}
-					} else {
-						fr.logger.Info("%s number %d no more data income", op, id)
-						return
-					}
-				}
+	if fr.pending.Load() == 0 {
+		fr.delayed.Close()
+	}
+
+	fr.logger.Info("%s input is over, waiting for %d tasks", op, fr.pending.Load())
+}
+
+// run processes the task when its host allows.
+func (fr *FetchProcessor) run(id int, task entity.Task) {
+	op := "FetchProcessor - run"
+
+	limited := fr.limiter != nil && task.IsReady()
+
+	if limited {
+		ok, wait := fr.limiter.Acquire(task)
+		if !ok {
+			if wait == 0 {
+				fr.logger.Debug("%s number %d task %s waits for its host", op, id, task.ID)
+				return
 			}
-		}(i)
+
+			task.CurrentState.NotBefore = time.Now().Add(wait)
+
+			if err := fr.delayed.Push(task); err != nil {
+				fr.logger.Error("%s - fr.delayed.Push: %v", op, err)
+			}
+
+			return
+		}
 	}
 
-	return nil
+	// input task is started, the next one could be read
+	if task.CurrentState.Status == entity.StateStatusInitial {
+		task.CurrentState.Status = entity.StateStatusProcessing
+		<-fr.fresh
+	}
+
+	// the limiter adapts the host rate to the attempt made
+	task = fr.process(id, task)
+
+	if !limited {
+		return
+	}
+
+	if next, ok := fr.limiter.Release(task); ok {
+		next.CurrentState.NotBefore = time.Now()
+
+		if err := fr.delayed.Push(next); err != nil {
+			fr.logger.Error("%s - fr.delayed.Push: %v", op, err)
+		}
+	}
+}
+
+// process makes the next attempt of the task and either delays it for retry or pushes out.
+// The task with the attempt made is returned.
+func (fr *FetchProcessor) process(id int, task entity.Task) entity.Task {
+	op := "FetchProcessor - process"
+
+	if !task.IsReady() {
+		fr.logger.Info("%s number %d received ready task with id %s", op, id, task.ID)
+
+		fr.done(task)
+
+		return task
+	}
+
+	req := fetcher.FetcherRequest{
+		ID:      task.ID,
+		Method:  task.InputParams.Method,
+		URL:     task.InputParams.URL,
+		Headers: task.InputParams.Headers,
+		Body:    task.InputParams.Body,
+
+		Timeout:  task.InputParams.Timeout,
+		Timeouts: task.InputParams.Timeouts,
+
+		ReadLimit:  task.InputParams.ReadLimit,
+		MaxRetries: task.CurrentState.MaxRetries,
+		Attempts:   task.OutputParams.Attempts,
+	}
+
+	if task.OutputParams.TimeStarted.IsZero() {
+		task.OutputParams.TimeStarted = time.Now()
+	}
+
+	resp, err := fr.fetcher.Attempt(fr.ctx, req)
+
+	task.CurrentState.Retries = resp.Retries
+	task.OutputParams.Attempts = resp.Attempts
+
+	if resp.Retry {
+		task.CurrentState.NotBefore = time.Now().Add(resp.RetryIn)
+
+		fr.logger.Info("%s number %d delayed task %s for %s", op, id, task.ID, resp.RetryIn)
+
+		if err := fr.delayed.Push(task); err == nil {
+			return task
+		}
+
+		// interrupted, the task is reported as is
+		resp.ErrorCategory, resp.Error = entity.ErrorCategoryContextCanceled, context.Canceled.Error()
+		err = context.Canceled
+	}
+
+	task.OutputParams.TimeCompleted = time.Now()
+
+	task.CurrentState.Status = entity.StateStatusCompleted
+	if err != nil {
+		task.CurrentState.Status = entity.StateStatusError
+	}
+
+	task.OutputParams.Error = resp.Error
+	task.OutputParams.ErrorCategory = resp.ErrorCategory
+
+	task.OutputParams.StatusCode = resp.StatusCode
+	task.OutputParams.Content = resp.Content
+	task.OutputParams.ContentLength = resp.ContentLength
+	task.OutputParams.BodyPath = resp.BodyPath
+	task.OutputParams.BodySize = resp.BodySize
+	task.OutputParams.BodySHA256 = resp.BodySHA256
+	task.OutputParams.Timing = resp.Timing
+	task.OutputParams.Proxy = resp.Proxy
+	task.OutputParams.TLS = resp.TLS
+	task.OutputParams.Redirects = resp.Redirects
+	task.OutputParams.FinalURL = resp.FinalURL
+
+	fr.done(task)
+
+	return task
+}
+
+// done pushes the task out, the last one closes the delay queue.
+func (fr *FetchProcessor) done(task entity.Task) {
+	op := "FetchProcessor - done"
+
+	err := fr.out.Push(task)
+	if err != nil {
+		fr.logger.Error("%s - fr.out.Push: %w", op, err)
+	}
+
+	if fr.pending.Add(-1) == 0 && fr.inputDone.Load() {
+		fr.delayed.Close()
+	}
 }
 
 // LazyShutdown -.
@@ -144,6 +316,7 @@ func (fr *FetchProcessor) Shutdown() error {
 	op := "FetchProcessor - Shutdown"
 
 	fr.shutdown.Store(true)
+	fr.delayed.Close()
 
 	c := make(chan struct{})
 
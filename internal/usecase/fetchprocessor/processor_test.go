@@ -0,0 +1,112 @@
+package fetchprocessor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/fetcher"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/queue"
+	"github.com/antonmisa/cliurlfetcher/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchProcessor_FetchesOthersDuringBackoff(t *testing.T) {
+	t.Parallel()
+
+	const (
+		backoff = 300 * time.Millisecond
+		fast    = 5
+	)
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/busy" {
+			res.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		res.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	l, _ := logger.NewFake()
+
+	f, err := fetcher.New(fetcher.Options{
+		Backoff: func(_, _ time.Duration, _ int, _ time.Duration, _ *http.Response) time.Duration {
+			return backoff
+		},
+	}, l)
+	require.NoError(t, err)
+
+	in := queue.New()
+	out := queue.New()
+
+	// the busy task goes first, so the single worker takes it before the fast ones
+	go func() {
+		in.Push(entity.Constructor("busy", testServer.URL+"/busy", 2))
+
+		for i := 1; i <= fast; i++ {
+			in.Push(entity.Constructor(strconv.Itoa(i), testServer.URL+"/fast", 1))
+		}
+
+		in.Close()
+	}()
+
+	results := make(chan []entity.Task)
+
+	go func() {
+		var tasks []entity.Task
+
+		for task, ok := out.Pop(); ok; task, ok = out.Pop() {
+			tasks = append(tasks, task)
+		}
+
+		results <- tasks
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	proc := New(ctx, 1, f, nil, in, out, l)
+
+	started := time.Now()
+
+	require.NoError(t, proc.Start())
+
+	// workers exit by themselves once every task is pushed out
+	stopped := make(chan error)
+
+	go func() {
+		stopped <- proc.LazyShutdown()
+	}()
+
+	select {
+	case err := <-stopped:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("processor is not stopped after all tasks are done")
+	}
+
+	out.Close()
+
+	tasks := <-results
+	require.Len(t, tasks, fast+1)
+
+	// the busy task is the last one, the others are done while it waits for the retry
+	busy := tasks[len(tasks)-1]
+	require.Equal(t, "busy", busy.ID)
+	require.Equal(t, entity.ErrorCategoryRetriesExhausted, busy.OutputParams.ErrorCategory)
+	require.Len(t, busy.OutputParams.Attempts, 2)
+
+	retried := busy.OutputParams.Attempts[1].Started
+	require.False(t, retried.Before(started.Add(backoff)))
+
+	for _, task := range tasks[:fast] {
+		require.Equal(t, http.StatusOK, task.OutputParams.StatusCode, task.ID)
+		require.True(t, task.OutputParams.TimeCompleted.Before(retried), task.ID)
+	}
+}
@@ -0,0 +1,286 @@
+package filereader
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/pkg/httpheader"
+)
+
+const (
+	csvColumnURL        = "url"
+	csvColumnMethod     = "method"
+	csvColumnHeaders    = "headers"
+	csvColumnBody       = "body"
+	csvColumnID         = "id"
+	csvColumnMaxRetries = "max_retries"
+	csvColumnTimeout    = "timeout"
+	csvColumnConnect    = "connect_timeout"
+	csvColumnTLS        = "tls_timeout"
+	csvColumnHeader     = "header_timeout"
+	csvColumnIdle       = "idle_timeout"
+	csvColumnTags       = "tags"
+	csvColumnExpected   = "expected_status"
+	csvColumnReadLimit  = "read_limit"
+
+	utf8BOM = "\ufeff"
+
+	maxTimeoutSeconds = float64(math.MaxInt64 / int64(time.Second))
+)
+
+var (
+	ErrCSVNoURLColumn = errors.New("csv header has no url column")
+	ErrBadHeaders     = errors.New("bad headers value")
+)
+
+// csvColumns maps known column names to their position in a record.
+type csvColumns map[string]int
+
+// isCSVHeader reports whether the line looks like a header of a structured
+// input file, i.e. it contains an url column.
+func isCSVHeader(line string) bool {
+	r := csv.NewReader(strings.NewReader(line))
+	r.TrimLeadingSpace = true
+
+	record, err := r.Read()
+	if err != nil {
+		return false
+	}
+
+	_, err = newCSVColumns(record)
+
+	return err == nil
+}
+
+// newCSVColumns returns column positions of the header record.
+func newCSVColumns(header []string) (csvColumns, error) {
+	cols := make(csvColumns, len(header))
+
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, utf8BOM)
+		}
+
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := cols[csvColumnURL]; !ok {
+		return nil, ErrCSVNoURLColumn
+	}
+
+	return cols, nil
+}
+
+func (c csvColumns) value(record []string, name string) string {
+	i, ok := c[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[i])
+}
+
+// task builds a task from a csv record, defaultID is used when no id column
+// is present or its value is empty.
+func (c csvColumns) task(record []string, defaultID string) (entity.Task, error) {
+	id := c.value(record, csvColumnID)
+	if id == "" {
+		id = defaultID
+	}
+
+	// zero is replaced by the reader default
+	maxRetries := 0
+
+	if s := c.value(record, csvColumnMaxRetries); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return entity.Task{}, fmt.Errorf("bad max_retries value %q", s)
+		}
+
+		maxRetries = n
+	}
+
+	headers, err := parseHeaders(c.value(record, csvColumnHeaders))
+	if err != nil {
+		return entity.Task{}, err
+	}
+
+	params := entity.InputParams{
+		URL:     c.value(record, csvColumnURL),
+		Method:  strings.ToUpper(c.value(record, csvColumnMethod)),
+		Headers: headers,
+		Tags:    parseTags(c.value(record, csvColumnTags)),
+	}
+
+	timeouts := []struct {
+		column string
+		dst    *time.Duration
+	}{
+		{csvColumnTimeout, &params.Timeout},
+		{csvColumnConnect, &params.Timeouts.Connect},
+		{csvColumnTLS, &params.Timeouts.TLSHandshake},
+		{csvColumnHeader, &params.Timeouts.ResponseHeader},
+		{csvColumnIdle, &params.Timeouts.BodyIdle},
+	}
+
+	for _, t := range timeouts {
+		if s := c.value(record, t.column); s != "" {
+			if *t.dst, err = parseTimeout(s); err != nil {
+				return entity.Task{}, err
+			}
+		}
+	}
+
+	if s := c.value(record, csvColumnReadLimit); s != "" {
+		if params.ReadLimit, err = parseReadLimit(s); err != nil {
+			return entity.Task{}, err
+		}
+	}
+
+	if s := c.value(record, csvColumnExpected); s != "" {
+		if params.ExpectedStatus, err = parseStatus(s); err != nil {
+			return entity.Task{}, err
+		}
+	}
+
+	// body is taken verbatim, surrounding spaces may be meaningful
+	if i, ok := c[csvColumnBody]; ok && i < len(record) {
+		params.Body = record[i]
+	}
+
+	return entity.ConstructorWithParams(id, params, maxRetries), nil
+}
+
+// parseHeaders accepts either a json object {"Name": "value"} or
+// "Name: value" pairs delimited by new lines.
+func parseHeaders(s string) (http.Header, error) {
+	headers, err := httpheader.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadHeaders, err)
+	}
+
+	return headers, nil
+}
+
+// parseTags splits comma or semicolon delimited list of tags.
+func parseTags(s string) []string {
+	tags := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';'
+	})
+
+	res := tags[:0]
+
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			res = append(res, tag)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil
+	}
+
+	return res
+}
+
+// parseTimeout accepts go duration (1m30s) or number of seconds.
+func parseTimeout(s string) (time.Duration, error) {
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		// inf, nan and too many seconds overflow the duration
+		if math.IsNaN(sec) || sec < 0 || sec > maxTimeoutSeconds {
+			return 0, fmt.Errorf("bad timeout value %q", s)
+		}
+
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("bad timeout value %q", s)
+	}
+
+	return d, nil
+}
+
+// parseStatus accepts the codes of the standard classes, 100-599.
+func parseStatus(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 100 || n > 599 {
+		return 0, fmt.Errorf("bad expected_status value %q", s)
+	}
+
+	return n, nil
+}
+
+func parseReadLimit(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad read_limit value %q", s)
+	}
+
+	return n, nil
+}
+
+// readCSV pushes a task for every record of a structured input file,
+// the first record must be the header.
+func (fr *FileReader) readCSV(name string, r io.Reader) {
+	op := "FileReader - readCSV"
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		fr.logger.Error("%s - cr.Read header: %w", op, err)
+		return
+	}
+
+	cols, err := newCSVColumns(header)
+	if err != nil {
+		fr.logger.Error("%s - newCSVColumns: %w", op, err)
+		return
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			fr.reportMalformed(op, name, parseErr.StartLine, err)
+			continue
+		}
+
+		if err != nil {
+			fr.logger.Error("%s - cr.Read: %w", op, err)
+			return
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		task, err := cols.task(record, lineID(name, line))
+		if err == nil {
+			err = validateTask(task)
+		}
+
+		if err != nil {
+			fr.reportMalformed(op, name, line, err)
+			continue
+		}
+
+		if !fr.push(task) {
+			return
+		}
+	}
+}
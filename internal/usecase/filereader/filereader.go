@@ -2,10 +2,14 @@ package filereader
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -20,22 +24,69 @@ const (
 	defaultMaxRetries      = 3
 )
 
+// Format of the input file.
+type Format string
+
+const (
+	FormatAuto  Format = "auto"
+	FormatText  Format = "text"
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown input format")
+	ErrBadURL        = errors.New("bad url")
+	ErrBadMethod     = errors.New("bad method")
+)
+
+// ParseFormat returns the format by its name, empty name means FormatAuto.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatAuto, nil
+	case FormatAuto, FormatText, FormatCSV, FormatJSONL:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFormat, s)
+	}
+}
+
+// Source is a named input, Name is used in task ids and error reports.
+type Source struct {
+	Name string
+	R    io.Reader
+}
+
 type FileReader struct {
-	r               io.Reader
+	sources         []Source
+	format          Format
 	logger          logger.Interface
 	queue           usecase.QueueWriter
 	ctx             context.Context
 	wg              sync.WaitGroup
 	shutdown        atomic.Bool
+	malformed       atomic.Int64
+	seq             int
+	maxRetries      int
 	shutdownTimeout time.Duration
 }
 
 var _ usecase.StartStoper = (*FileReader)(nil)
 
-func New(ctx context.Context, r io.Reader, q usecase.QueueWriter, l logger.Interface) *FileReader {
+// New returns reader of the sources, they are read one by one. Task ids are line
+// numbers, prefixed with the source name when there is more than one source.
+// MaxRetries is used for tasks not having own limit, zero means default.
+func New(ctx context.Context, sources []Source, format Format, maxRetries int, q usecase.QueueWriter, l logger.Interface) *FileReader {
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+
 	fr := &FileReader{
 		ctx:             ctx,
-		r:               r,
+		sources:         sources,
+		format:          format,
+		maxRetries:      maxRetries,
 		logger:          l,
 		queue:           q,
 		shutdown:        atomic.Bool{},
@@ -54,37 +105,198 @@ func (fr *FileReader) Start() error {
 	go func() {
 		defer fr.wg.Done()
 
-		fileScanner := bufio.NewScanner(fr.r)
-
-		lineNumber := 1
+		for _, src := range fr.sources {
+			name := src.Name
+			if len(fr.sources) == 1 {
+				name = ""
+			}
 
-		for fileScanner.Scan() {
-			if fr.shutdown.Load() {
+			if !fr.readSource(name, src.R) {
 				return
 			}
 
-			select {
-			case <-fr.ctx.Done():
-				return
-			default:
-				url := fileScanner.Text()
-				task := entity.Constructor(strconv.Itoa(lineNumber), url, defaultMaxRetries)
+			fr.logger.Info("%s input %s read completed", op, src.Name)
+		}
+
+		fr.logger.Info("%s all input read completed, malformed lines: %d", op, fr.Malformed())
+	}()
+
+	return nil
+}
 
-				err := fr.queue.Push(task)
-				if err != nil {
-					fr.logger.Error(" - fr.queue.Push: %w", op, err)
-				}
+// readSource reads single source, returns false when reading must be stopped.
+func (fr *FileReader) readSource(name string, r io.Reader) bool {
+	op := "FileReader - readSource"
+
+	format := fr.format
+
+	if format == FormatAuto || format == "" {
+		var err error
+
+		format, r, err = detectFormat(r)
+		if err != nil {
+			fr.logger.Error("%s - detectFormat %s: %w", op, name, err)
+			return true
+		}
+
+		fr.logger.Info("%s detected input format %s of %s", op, format, name)
+	}
+
+	switch format {
+	case FormatCSV:
+		fr.readCSV(name, r)
+	case FormatJSONL:
+		fr.readJSONL(name, r)
+	default:
+		fr.readLines(name, r)
+	}
+
+	return !fr.stopped()
+}
+
+// stopped reports whether reading must be stopped.
+func (fr *FileReader) stopped() bool {
+	return fr.shutdown.Load() || fr.ctx.Err() != nil
+}
+
+// Malformed returns number of lines skipped because they could not be parsed.
+func (fr *FileReader) Malformed() int64 {
+	return fr.malformed.Load()
+}
+
+// detectFormat looks at the first non-empty line, returned reader yields the whole input.
+func detectFormat(r io.Reader) (Format, io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	var head bytes.Buffer
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", nil, err
+		}
+
+		head.WriteString(line)
+
+		trimmed := strings.TrimSpace(strings.TrimPrefix(line, utf8BOM))
+
+		if trimmed != "" || err != nil {
+			r = io.MultiReader(&head, br)
+
+			switch {
+			case strings.HasPrefix(trimmed, "{"):
+				return FormatJSONL, r, nil
+			case isCSVHeader(line):
+				return FormatCSV, r, nil
+			default:
+				return FormatText, r, nil
 			}
+		}
+	}
+}
+
+// readLines pushes a task for every line of a plain list of urls,
+// empty lines and lines started with # are skipped.
+func (fr *FileReader) readLines(name string, r io.Reader) {
+	op := "FileReader - readLines"
+
+	fileScanner := bufio.NewScanner(r)
+
+	lineNumber := 1
 
-			lineNumber++
+	for ; fileScanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(fileScanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
 
-		fr.logger.Info("%s input file read completed", op)
-	}()
+		task := entity.Constructor(lineID(name, lineNumber), line, 0)
+
+		if err := validateTask(task); err != nil {
+			fr.reportMalformed(op, name, lineNumber, err)
+			continue
+		}
+
+		if !fr.push(task) {
+			return
+		}
+	}
+
+	if err := fileScanner.Err(); err != nil {
+		fr.logger.Error("%s - fileScanner.Scan at line %d: %w", op, lineNumber, err)
+	}
+}
+
+// push sends the task to the queue, returns false when reading must be stopped.
+func (fr *FileReader) push(task entity.Task) bool {
+	op := "FileReader - push"
+
+	if fr.stopped() {
+		return false
+	}
+
+	select {
+	case <-fr.ctx.Done():
+		return false
+	default:
+		fr.seq++
+		task.Seq = fr.seq
+
+		if task.CurrentState.MaxRetries == 0 {
+			task.CurrentState.MaxRetries = fr.maxRetries
+		}
+
+		err := fr.queue.Push(task)
+		if err != nil {
+			fr.logger.Error("%s - fr.queue.Push: %w", op, err)
+		}
+	}
+
+	return true
+}
+
+func (fr *FileReader) reportMalformed(op, name string, line int, err error) {
+	fr.malformed.Add(1)
+
+	fr.logger.Error("%s - malformed line %s skipped: %v", op, lineID(name, line), err)
+}
+
+// lineID returns line number prefixed by the source name if any, e.g. list.csv:42.
+func lineID(name string, line int) string {
+	if name == "" {
+		return strconv.Itoa(line)
+	}
+
+	return name + ":" + strconv.Itoa(line)
+}
+
+// validateTask checks that the task could be sent at all.
+func validateTask(task entity.Task) error {
+	u, err := url.Parse(task.InputParams.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadURL, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q", ErrBadURL, task.InputParams.URL)
+	}
+
+	if m := task.InputParams.Method; m != "" && strings.IndexFunc(m, isNotTokenRune) != -1 {
+		return fmt.Errorf("%w: %q", ErrBadMethod, m)
+	}
 
 	return nil
 }
 
+// isNotTokenRune reports whether r is not allowed in http token (RFC 9110).
+func isNotTokenRune(r rune) bool {
+	if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+		return false
+	}
+
+	return !strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
+
 // LazyShutdown -.
 func (fr *FileReader) LazyShutdown() error {
 	op := "FileReader - LazyShutdown"
@@ -3,10 +3,14 @@ package filereader
 import (
 	"context"
 	"io"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/antonmisa/cliurlfetcher/internal/entity"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase/mocks"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase/queue"
 	"github.com/antonmisa/cliurlfetcher/pkg/logger"
 	"github.com/stretchr/testify/require"
@@ -27,6 +31,11 @@ func (hr *HelperReader) Read(p []byte) (int, error) {
 	return n, nil
 }
 
+func withSeq(t entity.Task, seq int) entity.Task {
+	t.Seq = seq
+	return t
+}
+
 func TestFileReader_Start(t *testing.T) {
 	type ts struct {
 		ok bool
@@ -61,14 +70,156 @@ func TestFileReader_Start(t *testing.T) {
 			},
 			fr: func(ctx context.Context, r io.Reader, qw usecase.QueueWriter) *FileReader {
 				l, _ := logger.NewFake()
-				return New(ctx, r, qw, l)
+				return New(ctx, []Source{{Name: "test", R: r}}, FormatAuto, 0, qw, l)
+			},
+			rv: rvs{
+				err: nil,
+				ts: []ts{
+					{
+						ok: true,
+						t:  withSeq(entity.Constructor("1", "http://www.yandex.ru", 3), 1),
+					},
+				},
+			},
+		},
+		{
+			name: "ok csv",
+			args: args{
+				ctx: context.Background(),
+				r: HelperReader{
+					Buf: []byte("url,method,headers,body,id,max_retries,timeout,header_timeout\n" +
+						"http://www.yandex.ru,post,\"Content-Type: application/json\nX-Token: 1\",\"{\"\"a\"\":1}\",req-1,5,10,2s\n"),
+				},
+				queue: queue.New(),
+			},
+			fr: func(ctx context.Context, r io.Reader, qw usecase.QueueWriter) *FileReader {
+				l, _ := logger.NewFake()
+				return New(ctx, []Source{{Name: "test", R: r}}, FormatAuto, 0, qw, l)
 			},
 			rv: rvs{
 				err: nil,
 				ts: []ts{
 					{
 						ok: true,
-						t:  entity.Constructor("1", "http://www.yandex.ru", 3),
+						t: withSeq(entity.ConstructorWithParams("req-1", entity.InputParams{
+							URL:    "http://www.yandex.ru",
+							Method: http.MethodPost,
+							Headers: http.Header{
+								"Content-Type": {"application/json"},
+								"X-Token":      {"1"},
+							},
+							Body:     `{"a":1}`,
+							Timeout:  10 * time.Second,
+							Timeouts: entity.Timeouts{ResponseHeader: 2 * time.Second},
+						}, 5), 1),
+					},
+				},
+			},
+		},
+		{
+			name: "ok csv default id",
+			args: args{
+				ctx: context.Background(),
+				r: HelperReader{
+					Buf: []byte("\ufeffURL,Method\nhttp://www.yandex.ru,\n"),
+				},
+				queue: queue.New(),
+			},
+			fr: func(ctx context.Context, r io.Reader, qw usecase.QueueWriter) *FileReader {
+				l, _ := logger.NewFake()
+				return New(ctx, []Source{{Name: "test", R: r}}, FormatAuto, 0, qw, l)
+			},
+			rv: rvs{
+				err: nil,
+				ts: []ts{
+					{
+						ok: true,
+						t:  withSeq(entity.Constructor("2", "http://www.yandex.ru", 3), 1),
+					},
+				},
+			},
+		},
+		{
+			name: "ok jsonl",
+			args: args{
+				ctx: context.Background(),
+				r: HelperReader{
+					Buf: []byte("\n" + `{"id":"a","url":"http://www.yandex.ru","method":"put","headers":{"X-Token":["1","2"]},` +
+						`"body":{"a": 1},"timeout":"1.5","connect_timeout":2,"tls_timeout":"1s","idle_timeout":"500ms",` +
+						`"tags":["prod","api"],"expected_status":204}` + "\n"),
+				},
+				queue: queue.New(),
+			},
+			fr: func(ctx context.Context, r io.Reader, qw usecase.QueueWriter) *FileReader {
+				l, _ := logger.NewFake()
+				return New(ctx, []Source{{Name: "test", R: r}}, FormatAuto, 0, qw, l)
+			},
+			rv: rvs{
+				err: nil,
+				ts: []ts{
+					{
+						ok: true,
+						t: withSeq(entity.ConstructorWithParams("a", entity.InputParams{
+							URL:     "http://www.yandex.ru",
+							Method:  http.MethodPut,
+							Headers: http.Header{"X-Token": {"1", "2"}},
+							Body:    `{"a":1}`,
+							Timeout: 1500 * time.Millisecond,
+							Timeouts: entity.Timeouts{
+								Connect:      2 * time.Second,
+								TLSHandshake: time.Second,
+								BodyIdle:     500 * time.Millisecond,
+							},
+							Tags:           []string{"prod", "api"},
+							ExpectedStatus: http.StatusNoContent,
+						}, 3), 1),
+					},
+				},
+			},
+		},
+		{
+			name: "malformed jsonl skipped",
+			args: args{
+				ctx: context.Background(),
+				r: HelperReader{
+					Buf: []byte(`{"url":"yandex.ru"}` + "\n" + `{"url":"http://www.yandex.ru","unknown":1}` + "\n" +
+						`{"url":"http://www.yandex.ru"}`),
+				},
+				queue: queue.New(),
+			},
+			fr: func(ctx context.Context, r io.Reader, qw usecase.QueueWriter) *FileReader {
+				l, _ := logger.NewFake()
+				return New(ctx, []Source{{Name: "test", R: r}}, FormatJSONL, 0, qw, l)
+			},
+			rv: rvs{
+				err: nil,
+				ts: []ts{
+					{
+						ok: true,
+						t:  withSeq(entity.Constructor("3", "http://www.yandex.ru", 3), 1),
+					},
+				},
+			},
+		},
+		{
+			name: "malformed text skipped",
+			args: args{
+				ctx: context.Background(),
+				r: HelperReader{
+					Buf: []byte("\n# comment\nwww.yandex.ru\n http://www.yandex.ru \n"),
+				},
+				queue: queue.New(),
+			},
+			fr: func(ctx context.Context, r io.Reader, qw usecase.QueueWriter) *FileReader {
+				l, _ := logger.NewFake()
+				return New(ctx, []Source{{Name: "test", R: r}}, FormatAuto, 0, qw, l)
+			},
+			rv: rvs{
+				err: nil,
+				ts: []ts{
+					{
+						ok: true,
+						t:  withSeq(entity.Constructor("4", "http://www.yandex.ru", 3), 1),
 					},
 				},
 			},
@@ -92,9 +243,132 @@ func TestFileReader_Start(t *testing.T) {
 
 			tc.args.queue.Close()
 
-			got, ok := tc.args.queue.Pop()
-			require.Equal(t, ok, tc.rv.ts[0].ok)
-			require.Equal(t, got, tc.rv.ts[0].t)
+			for _, want := range tc.rv.ts {
+				got, ok := tc.args.queue.Pop()
+				require.Equal(t, ok, want.ok)
+				require.Equal(t, got, want.t)
+			}
+		})
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    http.Header
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			s:    "",
+			want: nil,
+		},
+		{
+			name: "lines",
+			s:    "Accept: text/html\nX-Token: a:b",
+			want: http.Header{"Accept": {"text/html"}, "X-Token": {"a:b"}},
+		},
+		{
+			name: "json",
+			s:    `{"accept": "text/html"}`,
+			want: http.Header{"Accept": {"text/html"}},
+		},
+		{
+			name: "json of many values",
+			s:    `{"accept": ["text/html", "*/*"]}`,
+			want: http.Header{"Accept": {"text/html", "*/*"}},
+		},
+		{
+			name:    "bad line",
+			s:       "Accept",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseHeaders(tc.s)
+			if tc.wantErr {
+				require.ErrorIs(t, err, ErrBadHeaders)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
 		})
 	}
 }
+
+func TestParseTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "1.5", want: 1500 * time.Millisecond},
+		{in: "1m30s", want: 90 * time.Second},
+		{in: "0", want: 0},
+		{in: "-1", wantErr: true},
+		{in: "inf", wantErr: true},
+		{in: "NaN", wantErr: true},
+		{in: "1e300", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseTimeout(tc.in)
+		if tc.wantErr {
+			require.Error(t, err, tc.in)
+			continue
+		}
+
+		require.NoError(t, err, tc.in)
+		require.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"100", "204", "599"} {
+		_, err := parseStatus(s)
+		require.NoError(t, err, s)
+	}
+
+	for _, s := range []string{"99", "600", "999", "2xx"} {
+		_, err := parseStatus(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestFileReader_StartMultipleSources(t *testing.T) {
+	t.Parallel()
+
+	l, _ := logger.NewFake()
+
+	queueMock := mocks.NewQueueWriter(t)
+
+	queueMock.On("Push", withSeq(entity.Constructor("list.txt:2", "http://www.yandex.ru", 5), 1)).
+		Return(nil).Once()
+
+	queueMock.On("Push", withSeq(entity.Constructor("stdin:1", "http://www.google.com", 5), 2)).
+		Return(nil).Once()
+
+	queueMock.On("Push", withSeq(entity.Constructor("own-id", "http://www.bing.com", 2), 3)).
+		Return(nil).Once()
+
+	fr := New(context.Background(), []Source{
+		{Name: "list.txt", R: strings.NewReader("\nhttp://www.yandex.ru\n")},
+		{Name: "stdin", R: strings.NewReader("http://www.google.com")},
+		{Name: "list.jsonl", R: strings.NewReader(`{"id":"own-id","url":"http://www.bing.com","max_retries":2}`)},
+	}, FormatAuto, 5, queueMock, l)
+
+	require.NoError(t, fr.Start())
+	require.NoError(t, fr.LazyShutdown())
+}
@@ -0,0 +1,211 @@
+package filereader
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/pkg/httpheader"
+)
+
+const maxJSONLineSize = 1024 * 1024
+
+var ErrJSONLBadValue = errors.New("bad value")
+
+// jsonTask describes a single line of JSON Lines input.
+type jsonTask struct {
+	ID             string          `json:"id"`
+	URL            string          `json:"url"`
+	Method         string          `json:"method"`
+	Headers        json.RawMessage `json:"headers"`
+	Body           json.RawMessage `json:"body"`
+	Timeout        json.RawMessage `json:"timeout"`
+	ConnectTimeout json.RawMessage `json:"connect_timeout"`
+	TLSTimeout     json.RawMessage `json:"tls_timeout"`
+	HeaderTimeout  json.RawMessage `json:"header_timeout"`
+	IdleTimeout    json.RawMessage `json:"idle_timeout"`
+	Tags           []string        `json:"tags"`
+	ExpectedStatus int             `json:"expected_status"`
+	MaxRetries     int             `json:"max_retries"`
+	ReadLimit      int64           `json:"read_limit"`
+}
+
+// task builds a task, defaultID is used when id is not set.
+func (jt jsonTask) task(defaultID string) (entity.Task, error) {
+	id := jt.ID
+	if id == "" {
+		id = defaultID
+	}
+
+	// zero is replaced by the reader default
+	maxRetries := 0
+
+	if jt.MaxRetries < 0 {
+		return entity.Task{}, fmt.Errorf("%w: max_retries %d", ErrJSONLBadValue, jt.MaxRetries)
+	} else if jt.MaxRetries > 0 {
+		maxRetries = jt.MaxRetries
+	}
+
+	if jt.ExpectedStatus != 0 {
+		if _, err := parseStatus(strconv.Itoa(jt.ExpectedStatus)); err != nil {
+			return entity.Task{}, err
+		}
+	}
+
+	if jt.ReadLimit < 0 {
+		return entity.Task{}, fmt.Errorf("%w: read_limit %d", ErrJSONLBadValue, jt.ReadLimit)
+	}
+
+	headers, err := jsonHeaders(jt.Headers)
+	if err != nil {
+		return entity.Task{}, err
+	}
+
+	body, err := jsonBody(jt.Body)
+	if err != nil {
+		return entity.Task{}, err
+	}
+
+	params := entity.InputParams{
+		URL:            strings.TrimSpace(jt.URL),
+		Method:         strings.ToUpper(strings.TrimSpace(jt.Method)),
+		Headers:        headers,
+		Body:           body,
+		Tags:           jt.Tags,
+		ExpectedStatus: jt.ExpectedStatus,
+		ReadLimit:      jt.ReadLimit,
+	}
+
+	timeouts := []struct {
+		raw json.RawMessage
+		dst *time.Duration
+	}{
+		{jt.Timeout, &params.Timeout},
+		{jt.ConnectTimeout, &params.Timeouts.Connect},
+		{jt.TLSTimeout, &params.Timeouts.TLSHandshake},
+		{jt.HeaderTimeout, &params.Timeouts.ResponseHeader},
+		{jt.IdleTimeout, &params.Timeouts.BodyIdle},
+	}
+
+	for _, t := range timeouts {
+		if *t.dst, err = jsonTimeout(t.raw); err != nil {
+			return entity.Task{}, err
+		}
+	}
+
+	return entity.ConstructorWithParams(id, params, maxRetries), nil
+}
+
+// jsonTimeout accepts either "1m30s" or number of seconds, missing value means zero.
+func jsonTimeout(raw json.RawMessage) (time.Duration, error) {
+	if len(raw) == 0 || isJSONNull(raw) {
+		return 0, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+
+	return parseTimeout(s)
+}
+
+// jsonHeaders accepts {"Name": "value"} as well as {"Name": ["v1", "v2"]}.
+func jsonHeaders(raw json.RawMessage) (http.Header, error) {
+	if len(raw) == 0 || isJSONNull(raw) {
+		return nil, nil
+	}
+
+	headers, err := httpheader.ParseJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadHeaders, err)
+	}
+
+	return headers, nil
+}
+
+// jsonBody returns string bodies as-is, any other json value is sent encoded.
+func jsonBody(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || isJSONNull(raw) {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", fmt.Errorf("%w: body: %v", ErrJSONLBadValue, err)
+	}
+
+	return buf.String(), nil
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+// readJSONL pushes a task for every object of JSON Lines input,
+// empty lines are skipped.
+func (fr *FileReader) readJSONL(name string, r io.Reader) {
+	op := "FileReader - readJSONL"
+
+	fileScanner := bufio.NewScanner(r)
+	fileScanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxJSONLineSize)
+
+	lineNumber := 1
+
+	for ; fileScanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(fileScanner.Bytes())
+		if lineNumber == 1 {
+			line = bytes.TrimPrefix(line, []byte(utf8BOM))
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		var jt jsonTask
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&jt); err != nil {
+			fr.reportMalformed(op, name, lineNumber, err)
+			continue
+		}
+
+		if dec.More() {
+			fr.reportMalformed(op, name, lineNumber, fmt.Errorf("%w: trailing data", ErrJSONLBadValue))
+			continue
+		}
+
+		task, err := jt.task(lineID(name, lineNumber))
+		if err == nil {
+			err = validateTask(task)
+		}
+
+		if err != nil {
+			fr.reportMalformed(op, name, lineNumber, err)
+			continue
+		}
+
+		if !fr.push(task) {
+			return
+		}
+	}
+
+	if err := fileScanner.Err(); err != nil {
+		fr.logger.Error("%s - fileScanner.Scan at line %d: %w", op, lineNumber, err)
+	}
+}
@@ -0,0 +1,35 @@
+package filewriter
+
+import (
+	"sync/atomic"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase"
+)
+
+// CertWatch counts tasks having certificates expiring soon on their way to the writer.
+type CertWatch struct {
+	q        usecase.QueueReader
+	expiring atomic.Int64
+}
+
+var _ usecase.QueueReader = (*CertWatch)(nil)
+
+func NewCertWatch(q usecase.QueueReader) *CertWatch {
+	return &CertWatch{q: q}
+}
+
+// Pop -.
+func (cw *CertWatch) Pop() (entity.Task, bool) {
+	task, ok := cw.q.Pop()
+	if ok && task.OutputParams.TLS.ExpiresSoon {
+		cw.expiring.Add(1)
+	}
+
+	return task, ok
+}
+
+// Expiring returns number of the tasks popped with certificates expiring soon.
+func (cw *CertWatch) Expiring() int64 {
+	return cw.expiring.Load()
+}
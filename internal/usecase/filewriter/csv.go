@@ -0,0 +1,156 @@
+package filewriter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+var ErrUnknownColumn = errors.New("unknown column")
+
+// columnValue extracts a column value from the task.
+type columnValue func(task entity.Task) string
+
+// columns available for tabular output.
+var columns = map[string]columnValue{
+	"id":     func(t entity.Task) string { return t.ID },
+	"url":    func(t entity.Task) string { return t.InputParams.URL },
+	"method": func(t entity.Task) string { return t.InputParams.Method },
+	"tags":   func(t entity.Task) string { return strings.Join(t.InputParams.Tags, ",") },
+	"status": func(t entity.Task) string { return strconv.Itoa(t.OutputParams.StatusCode) },
+	"expected_status": func(t entity.Task) string {
+		if t.InputParams.ExpectedStatus == 0 {
+			return ""
+		}
+
+		return strconv.Itoa(t.InputParams.ExpectedStatus)
+	},
+	"content_length": func(t entity.Task) string { return strconv.FormatInt(t.OutputParams.ContentLength, 10) },
+	"retries":        func(t entity.Task) string { return strconv.Itoa(t.CurrentState.Retries) },
+	"duration_ms":    func(t entity.Task) string { return formatMS(t.Duration()) },
+	"error":          func(t entity.Task) string { return t.OutputParams.Error },
+	"error_category": func(t entity.Task) string { return string(t.OutputParams.ErrorCategory) },
+	"content":        func(t entity.Task) string { return t.OutputParams.Content },
+	"body_path":      func(t entity.Task) string { return t.OutputParams.BodyPath },
+	"body_size":      func(t entity.Task) string { return strconv.FormatInt(t.OutputParams.BodySize, 10) },
+	"body_sha256":    func(t entity.Task) string { return t.OutputParams.BodySHA256 },
+	"dns_ms":         func(t entity.Task) string { return formatMS(t.OutputParams.Timing.DNSLookup) },
+	"connect_ms":     func(t entity.Task) string { return formatMS(t.OutputParams.Timing.Connect) },
+	"tls_ms":         func(t entity.Task) string { return formatMS(t.OutputParams.Timing.TLSHandshake) },
+	"ttfb_ms":        func(t entity.Task) string { return formatMS(t.OutputParams.Timing.TimeToFirstByte) },
+	"transfer_ms":    func(t entity.Task) string { return formatMS(t.OutputParams.Timing.ContentTransfer) },
+	"attempts":       func(t entity.Task) string { return formatAttempts(t.OutputParams.Attempts) },
+	"proxy":          func(t entity.Task) string { return t.OutputParams.Proxy },
+	"tls_version":    func(t entity.Task) string { return t.OutputParams.TLS.Version },
+	"tls_cipher":     func(t entity.Task) string { return t.OutputParams.TLS.CipherSuite },
+	"cert_subject":   func(t entity.Task) string { return leaf(t).Subject },
+	"cert_issuer":    func(t entity.Task) string { return leaf(t).Issuer },
+	"cert_sans":      func(t entity.Task) string { return strings.Join(leaf(t).SANs, ",") },
+	"cert_not_after": func(t entity.Task) string {
+		if c := leaf(t); !c.NotAfter.IsZero() {
+			return c.NotAfter.UTC().Format(time.RFC3339)
+		}
+
+		return ""
+	},
+	"cert_days_left": func(t entity.Task) string {
+		if len(t.OutputParams.TLS.Chain) == 0 {
+			return ""
+		}
+
+		return strconv.Itoa(leaf(t).DaysLeft)
+	},
+	"cert_expires_soon": func(t entity.Task) string { return strconv.FormatBool(t.OutputParams.TLS.ExpiresSoon) },
+	"final_url":         func(t entity.Task) string { return t.OutputParams.FinalURL },
+	"redirects":         func(t entity.Task) string { return formatRedirects(t.OutputParams.Redirects) },
+}
+
+// leaf returns the server certificate, zero if there is no tls.
+func leaf(t entity.Task) entity.Certificate {
+	if len(t.OutputParams.TLS.Chain) == 0 {
+		return entity.Certificate{}
+	}
+
+	return t.OutputParams.TLS.Chain[0]
+}
+
+func formatMS(d time.Duration) string {
+	return strconv.FormatFloat(durationMS(d), 'f', 3, 64)
+}
+
+// DefaultColumns is a narrow report without the content.
+var DefaultColumns = []string{"id", "url", "status", "content_length", "retries", "duration_ms", "error_category", "error"}
+
+// CSVFormatter renders tasks as delimited records with a header row.
+type CSVFormatter struct {
+	comma   rune
+	columns []string
+}
+
+var _ Formatter = CSVFormatter{}
+
+// NewCSVFormatter returns formatter with the given delimiter, empty columns means DefaultColumns.
+func NewCSVFormatter(comma rune, cols []string) (CSVFormatter, error) {
+	if len(cols) == 0 {
+		cols = DefaultColumns
+	}
+
+	normalized := make([]string, 0, len(cols))
+
+	for _, c := range cols {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if _, ok := columns[c]; !ok {
+			return CSVFormatter{}, fmt.Errorf("%w: %s", ErrUnknownColumn, c)
+		}
+
+		normalized = append(normalized, c)
+	}
+
+	return CSVFormatter{
+		comma:   comma,
+		columns: normalized,
+	}, nil
+}
+
+// Header -.
+func (f CSVFormatter) Header() string {
+	s, _ := f.record(f.columns)
+
+	return s
+}
+
+// Format -.
+func (f CSVFormatter) Format(task entity.Task) (string, error) {
+	values := make([]string, 0, len(f.columns))
+
+	for _, c := range f.columns {
+		values = append(values, columns[c](task))
+	}
+
+	return f.record(values)
+}
+
+// Footer -.
+func (CSVFormatter) Footer() string {
+	return ""
+}
+
+func (f CSVFormatter) record(values []string) (string, error) {
+	var sb strings.Builder
+
+	w := csv.NewWriter(&sb)
+	w.Comma = f.comma
+
+	if err := w.Write(values); err != nil {
+		return "", err
+	}
+
+	w.Flush()
+
+	return sb.String(), w.Error()
+}
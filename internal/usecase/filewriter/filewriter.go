@@ -19,6 +19,7 @@ const (
 type FileWriter struct {
 	logger          logger.Interface
 	sw              io.StringWriter
+	formatter       Formatter
 	queue           usecase.QueueReader
 	ctx             context.Context
 	wg              sync.WaitGroup
@@ -28,10 +29,11 @@ type FileWriter struct {
 
 var _ usecase.StartStoper = (*FileWriter)(nil)
 
-func New(ctx context.Context, sw io.StringWriter, q usecase.QueueReader, l logger.Interface) *FileWriter {
+func New(ctx context.Context, sw io.StringWriter, f Formatter, q usecase.QueueReader, l logger.Interface) *FileWriter {
 	fr := &FileWriter{
 		ctx:             ctx,
 		sw:              sw,
+		formatter:       f,
 		logger:          l,
 		queue:           q,
 		shutdown:        atomic.Bool{},
@@ -45,6 +47,12 @@ func New(ctx context.Context, sw io.StringWriter, q usecase.QueueReader, l logge
 func (fw *FileWriter) Start() error {
 	op := "FileWriter - Start"
 
+	if header := fw.formatter.Header(); header != "" {
+		if _, err := fw.sw.WriteString(header); err != nil {
+			return fmt.Errorf("%s - fw.sw.WriteString: %w", op, err)
+		}
+	}
+
 	fw.wg.Add(1)
 
 	go func() {
@@ -62,9 +70,14 @@ func (fw *FileWriter) Start() error {
 				return
 			default:
 				if task, ok := fw.queue.Pop(); ok {
-					output := fmt.Sprintf("---------------\nCompleted url: %s, status: %d, contentlength: %d, content: %s\n", task.InputParams.URL, task.OutputParams.StatusCode, task.OutputParams.ContentLength, task.OutputParams.Content)
+					output, err := fw.formatter.Format(task)
+					if err != nil {
+						fw.logger.Error("%s - fw.formatter.Format task %s: %v", op, task.ID, err)
 
-					_, err := fw.sw.WriteString(output)
+						continue
+					}
+
+					_, err = fw.sw.WriteString(output)
 					if err != nil {
 						fw.logger.Error(" - fw.sw.WriteString: %w", op, err)
 					}
@@ -128,7 +141,10 @@ func (fw *FileWriter) Shutdown() error {
 func (fw *FileWriter) Done() {
 	op := "FileWriter - Done"
 
-	output := "---------------\nDONE"
+	output := fw.formatter.Footer()
+	if output == "" {
+		return
+	}
 
 	_, err := fw.sw.WriteString(output)
 	if err != nil {
@@ -3,8 +3,12 @@ package filewriter
 import (
 	"context"
 	"io"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/antonmisa/cliurlfetcher/internal/entity"
 	"github.com/antonmisa/cliurlfetcher/internal/usecase"
@@ -40,7 +44,7 @@ func TestFileWriter_Start(t *testing.T) {
 			},
 			fr: func(ctx context.Context, f io.StringWriter, qr usecase.QueueReader) *FileWriter {
 				l, _ := logger.NewFake()
-				return New(ctx, f, qr, l)
+				return New(ctx, f, TextFormatter{}, qr, l)
 			},
 			mockTask: entity.Constructor("1", "http://www.yandex.ru", 3),
 			mockOk:   true,
@@ -49,6 +53,75 @@ func TestFileWriter_Start(t *testing.T) {
 				output: "---------------\nCompleted url: http://www.yandex.ru, status: 0, contentlength: 0, content: \n---------------\nDONE",
 			},
 		},
+		{
+			name: "ok with tags and unexpected status",
+			args: args{
+				ctx: context.Background(),
+				f:   strings.Builder{},
+			},
+			fr: func(ctx context.Context, f io.StringWriter, qr usecase.QueueReader) *FileWriter {
+				l, _ := logger.NewFake()
+				return New(ctx, f, TextFormatter{}, qr, l)
+			},
+			mockTask: entity.Task{
+				ID: "1",
+				InputParams: entity.InputParams{
+					URL:            "http://www.yandex.ru",
+					Tags:           []string{"prod", "api"},
+					ExpectedStatus: 200,
+				},
+				OutputParams: entity.OutputParams{
+					StatusCode: 503,
+					Attempts: []entity.Attempt{
+						{Number: 1, Error: "connection reset", ErrorCategory: entity.ErrorCategoryOther, Backoff: 100 * time.Millisecond},
+						{Number: 2, StatusCode: 503, Backoff: time.Minute, RetryAfter: time.Hour, WaitTruncated: true},
+						{Number: 3, StatusCode: 503, RetryAfter: time.Second},
+					},
+				},
+			},
+			mockOk: true,
+			rv: rvs{
+				err: nil,
+				output: "---------------\nCompleted url: http://www.yandex.ru, status: 503, contentlength: 0, tags: prod,api, expected status: 200, " +
+					"attempts: other: connection reset (wait 100ms); 503 (wait 1m0s, retry-after 1h0m0s truncated); 503, content: \n---------------\nDONE",
+			},
+		},
+		{
+			name: "ok jsonl",
+			args: args{
+				ctx: context.Background(),
+				f:   strings.Builder{},
+			},
+			fr: func(ctx context.Context, f io.StringWriter, qr usecase.QueueReader) *FileWriter {
+				l, _ := logger.NewFake()
+				return New(ctx, f, JSONLFormatter{}, qr, l)
+			},
+			mockTask: entity.Task{
+				ID: "1",
+				InputParams: entity.InputParams{
+					URL:  "http://www.yandex.ru",
+					Tags: []string{"prod"},
+				},
+				OutputParams: entity.OutputParams{
+					StatusCode:    200,
+					Content:       "ok\n",
+					ContentLength: 3,
+					TimeStarted:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+					TimeCompleted: time.Date(2023, 1, 1, 0, 0, 1, 500000000, time.UTC),
+					Error:         "no more attempts",
+				},
+				CurrentState: entity.State{
+					Retries: 2,
+				},
+			},
+			mockOk: true,
+			rv: rvs{
+				err: nil,
+				output: `{"id":"1","url":"http://www.yandex.ru","tags":["prod"],"status_code":200,"content_length":3,"content":"ok\n",` +
+					`"retries":2,"time_started":"2023-01-01T00:00:00Z","time_completed":"2023-01-01T00:00:01.5Z","duration_ms":1500,` +
+					`"error":"no more attempts"}` + "\n",
+			},
+		},
 	}
 	for _, tc := range tests {
 		tc := tc
@@ -78,3 +151,275 @@ func TestFileWriter_Start(t *testing.T) {
 		})
 	}
 }
+
+func TestCSVFormatter(t *testing.T) {
+	task := entity.Task{
+		ID: "1",
+		InputParams: entity.InputParams{
+			URL: "http://www.yandex.ru",
+		},
+		OutputParams: entity.OutputParams{
+			StatusCode:    200,
+			Content:       "a,\"b\"\nc\td",
+			ContentLength: 10,
+		},
+		CurrentState: entity.State{
+			Retries: 1,
+		},
+	}
+
+	tlsTask := task
+	tlsTask.OutputParams.TLS = entity.TLSInfo{
+		Version:     "TLS 1.3",
+		CipherSuite: "TLS_AES_128_GCM_SHA256",
+		ExpiresSoon: true,
+		Chain: []entity.Certificate{{
+			Subject:  "CN=www.yandex.ru",
+			Issuer:   "CN=CA",
+			SANs:     []string{"yandex.ru", "www.yandex.ru"},
+			NotAfter: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+			DaysLeft: 9,
+		}},
+	}
+
+	abandonedTask := task
+	abandonedTask.OutputParams.Attempts = []entity.Attempt{
+		{Number: 1, StatusCode: 503, RetryAfter: time.Second, Backoff: time.Second},
+		{Number: 2, StatusCode: 503, RetryAfter: time.Hour, Abandoned: true},
+	}
+
+	tests := []struct {
+		name    string
+		task    *entity.Task
+		comma   rune
+		columns []string
+		header  string
+		row     string
+		err     error
+	}{
+		{
+			name:    "csv",
+			comma:   ',',
+			columns: []string{"id", "Status", "content"},
+			header:  "id,status,content\n",
+			row:     "1,200,\"a,\"\"b\"\"\nc\td\"\n",
+		},
+		{
+			name:    "tsv",
+			comma:   '\t',
+			columns: []string{"url", "retries", "content"},
+			header:  "url\tretries\tcontent\n",
+			row:     "http://www.yandex.ru\t1\t\"a,\"\"b\"\"\nc\td\"\n",
+		},
+		{
+			name:   "default columns",
+			comma:  ',',
+			header: "id,url,status,content_length,retries,duration_ms,error_category,error\n",
+			row:    "1,http://www.yandex.ru,200,10,1,0.000,,\n",
+		},
+		{
+			name:    "certificate",
+			task:    &tlsTask,
+			comma:   ',',
+			columns: []string{"tls_version", "cert_subject", "cert_sans", "cert_not_after", "cert_days_left", "cert_expires_soon"},
+			header:  "tls_version,cert_subject,cert_sans,cert_not_after,cert_days_left,cert_expires_soon\n",
+			row:     "TLS 1.3,CN=www.yandex.ru,\"yandex.ru,www.yandex.ru\",2024-01-31T00:00:00Z,9,true\n",
+		},
+		{
+			name:    "no certificate",
+			comma:   ',',
+			columns: []string{"tls_version", "cert_days_left", "cert_expires_soon"},
+			header:  "tls_version,cert_days_left,cert_expires_soon\n",
+			row:     ",,false\n",
+		},
+		{
+			name:    "abandoned",
+			task:    &abandonedTask,
+			comma:   ',',
+			columns: []string{"attempts"},
+			header:  "attempts\n",
+			row:     "503 (wait 1s); 503 (retry-after 1h0m0s abandoned)\n",
+		},
+		{
+			name:    "unknown column",
+			comma:   ',',
+			columns: []string{"id", "nope"},
+			err:     ErrUnknownColumn,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := NewCSVFormatter(tc.comma, tc.columns)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.header, f.Header())
+
+			in := task
+			if tc.task != nil {
+				in = *tc.task
+			}
+
+			row, err := f.Format(in)
+			require.NoError(t, err)
+			require.Equal(t, tc.row, row)
+		})
+	}
+}
+
+func TestTemplateFormatter(t *testing.T) {
+	task := entity.Task{
+		ID: "1",
+		InputParams: entity.InputParams{
+			URL:     "http://www.yandex.ru",
+			Headers: http.Header{"X-Token": {"secret"}},
+			Tags:    []string{"a", "b"},
+		},
+		OutputParams: entity.OutputParams{
+			StatusCode:    200,
+			Content:       "line \"one\"\nline two",
+			TimeStarted:   time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+			TimeCompleted: time.Date(2023, 1, 1, 0, 0, 0, 250000000, time.UTC),
+		},
+	}
+
+	templateFile := filepath.Join(t.TempDir(), "tmpl")
+	require.NoError(t, os.WriteFile(templateFile, []byte("{{.ID}}:{{.OutputParams.StatusCode}}\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "helpers",
+			text: `{{.ID}} {{ms .Duration}} {{truncate 4 .OutputParams.Content}} {{header .InputParams.Headers "x-token"}} {{join "|" .InputParams.Tags}}`,
+			want: "1 250 line secret a|b\n",
+		},
+		{
+			name: "json and oneline",
+			text: `{"content":{{json .OutputParams.Content}},"line":"{{oneline .OutputParams.Content}}"}` + "\n",
+			want: `{"content":"line \"one\"\nline two","line":"line "one" line two"}` + "\n",
+		},
+		{
+			name: "file",
+			text: "@" + templateFile,
+			want: "1:200\n",
+		},
+		{
+			name:    "bad template",
+			text:    "{{.ID",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := NewTemplateFormatter(tc.text)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			got, err := f.Format(task)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestOrderedReader_Pop(t *testing.T) {
+	tests := []struct {
+		name   string
+		window int
+		in     []int
+		want   []int
+	}{
+		{
+			name:   "reordered",
+			window: 10,
+			in:     []int{3, 1, 2, 0, 5, 4},
+			want:   []int{1, 2, 3, 0, 4, 5},
+		},
+		{
+			name:   "window is full",
+			window: 2,
+			in:     []int{2, 3, 4, 1},
+			want:   []int{2, 3, 4, 1},
+		},
+		{
+			name:   "gap at the end",
+			window: 10,
+			in:     []int{3, 1},
+			want:   []int{1, 3},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			queueMock := mocks.NewQueue(t)
+
+			for _, seq := range tc.in {
+				queueMock.On("Pop").
+					Return(entity.Task{Seq: seq}, true).Once()
+			}
+
+			queueMock.On("Pop").
+				Return(entity.Task{}, false)
+
+			or := NewOrderedReader(queueMock, tc.window)
+
+			got := make([]int, 0, len(tc.want))
+
+			for {
+				task, ok := or.Pop()
+				if !ok {
+					break
+				}
+
+				got = append(got, task.Seq)
+			}
+
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCertWatch_Pop(t *testing.T) {
+	t.Parallel()
+
+	queueMock := mocks.NewQueue(t)
+
+	queueMock.On("Pop").
+		Return(entity.Task{OutputParams: entity.OutputParams{TLS: entity.TLSInfo{Version: "TLS 1.3", ExpiresSoon: true}}}, true).Once()
+	queueMock.On("Pop").
+		Return(entity.Task{OutputParams: entity.OutputParams{TLS: entity.TLSInfo{Version: "TLS 1.3"}}}, true).Once()
+	queueMock.On("Pop").
+		Return(entity.Task{}, false)
+
+	cw := NewCertWatch(queueMock)
+
+	for {
+		if _, ok := cw.Pop(); !ok {
+			break
+		}
+	}
+
+	require.Equal(t, int64(1), cw.Expiring())
+}
@@ -0,0 +1,178 @@
+package filewriter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+// Format of the output.
+type Format string
+
+const (
+	FormatText     Format = "text"
+	FormatJSONL    Format = "jsonl"
+	FormatCSV      Format = "csv"
+	FormatTSV      Format = "tsv"
+	FormatTemplate Format = "template"
+)
+
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// ParseFormat returns the format by its name, empty name means FormatText.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatJSONL, FormatCSV, FormatTSV, FormatTemplate:
+		return f, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFormat, s)
+	}
+}
+
+// Formatter renders finished tasks, Header is written before
+// the first task and Footer after the last one.
+type Formatter interface {
+	Header() string
+	Format(task entity.Task) (string, error)
+	Footer() string
+}
+
+// FormatterOptions -.
+type FormatterOptions struct {
+	// Columns of csv and tsv output, empty means DefaultColumns
+	Columns []string
+
+	// Template of template output, inline or @path to the file
+	Template string
+}
+
+// NewFormatter returns formatter for the given format.
+func NewFormatter(format Format, opts FormatterOptions) (Formatter, error) {
+	switch format {
+	case FormatText, "":
+		return TextFormatter{}, nil
+	case FormatJSONL:
+		return JSONLFormatter{}, nil
+	case FormatCSV:
+		return NewCSVFormatter(',', opts.Columns)
+	case FormatTSV:
+		return NewCSVFormatter('\t', opts.Columns)
+	case FormatTemplate:
+		return NewTemplateFormatter(opts.Template)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// formatAttempts renders attempts history in short form, e.g. "503 (wait 100ms); timeout: ... (wait 200ms); 200".
+func formatAttempts(attempts []entity.Attempt) string {
+	parts := make([]string, 0, len(attempts))
+
+	for _, a := range attempts {
+		s := strconv.Itoa(a.StatusCode)
+		if a.Error != "" {
+			s = string(a.ErrorCategory) + ": " + a.Error
+		}
+
+		switch {
+		case a.WaitTruncated:
+			s += " (wait " + a.Backoff.String() + ", retry-after " + a.RetryAfter.String() + " truncated)"
+		case a.Abandoned:
+			s += " (retry-after " + a.RetryAfter.String() + " abandoned)"
+		case a.Backoff > 0:
+			s += " (wait " + a.Backoff.String() + ")"
+		}
+
+		parts = append(parts, s)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// formatRedirects renders the chain, e.g. "301 http://a.com/ -> https://a.com/; 302 https://a.com/ -> https://www.a.com/".
+func formatRedirects(redirects []entity.Redirect) string {
+	parts := make([]string, 0, len(redirects))
+
+	for _, r := range redirects {
+		parts = append(parts, strconv.Itoa(r.StatusCode)+" "+r.URL+" -> "+r.Location)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// TextFormatter renders tasks in human readable form.
+type TextFormatter struct{}
+
+var _ Formatter = TextFormatter{}
+
+// Header -.
+func (TextFormatter) Header() string {
+	return ""
+}
+
+// Format -.
+func (TextFormatter) Format(task entity.Task) (string, error) {
+	var extra strings.Builder
+
+	if len(task.InputParams.Tags) > 0 {
+		fmt.Fprintf(&extra, ", tags: %s", strings.Join(task.InputParams.Tags, ","))
+	}
+
+	if !task.IsExpected() {
+		fmt.Fprintf(&extra, ", expected status: %d", task.InputParams.ExpectedStatus)
+	}
+
+	if t := task.OutputParams.Timing; t.Total > 0 {
+		fmt.Fprintf(&extra, ", timing: dns %s, connect %s, tls %s, ttfb %s, transfer %s",
+			t.DNSLookup, t.Connect, t.TLSHandshake, t.TimeToFirstByte, t.ContentTransfer)
+	}
+
+	if len(task.OutputParams.Redirects) > 0 {
+		fmt.Fprintf(&extra, ", redirects: %s", formatRedirects(task.OutputParams.Redirects))
+	}
+
+	if info := task.OutputParams.TLS; info.Version != "" {
+		fmt.Fprintf(&extra, ", tls: %s %s", info.Version, info.CipherSuite)
+
+		if len(info.Chain) > 0 {
+			c := info.Chain[0]
+			fmt.Fprintf(&extra, ", cert: %s, issuer: %s, sans: %s, not after: %s, days left: %d",
+				c.Subject, c.Issuer, strings.Join(c.SANs, ","), c.NotAfter.UTC().Format(time.RFC3339), c.DaysLeft)
+		}
+
+		if info.ExpiresSoon {
+			extra.WriteString(", CERT EXPIRES SOON")
+		}
+	}
+
+	if task.OutputParams.Proxy != "" {
+		fmt.Fprintf(&extra, ", proxy: %s", task.OutputParams.Proxy)
+	}
+
+	if task.OutputParams.ErrorCategory != "" {
+		fmt.Fprintf(&extra, ", error: %s: %s", task.OutputParams.ErrorCategory, task.OutputParams.Error)
+	}
+
+	if len(task.OutputParams.Attempts) > 1 {
+		fmt.Fprintf(&extra, ", attempts: %s", formatAttempts(task.OutputParams.Attempts))
+	}
+
+	if task.OutputParams.BodyPath != "" {
+		fmt.Fprintf(&extra, ", body: %s, size: %d, sha256: %s",
+			task.OutputParams.BodyPath, task.OutputParams.BodySize, task.OutputParams.BodySHA256)
+	}
+
+	return fmt.Sprintf("---------------\nCompleted url: %s, status: %d, contentlength: %d%s, content: %s\n",
+		task.InputParams.URL, task.OutputParams.StatusCode, task.OutputParams.ContentLength, extra.String(), task.OutputParams.Content), nil
+}
+
+// Footer -.
+func (TextFormatter) Footer() string {
+	return "---------------\nDONE"
+}
@@ -0,0 +1,215 @@
+package filewriter
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+// jsonTiming is phases timing in milliseconds.
+type jsonTiming struct {
+	DNSLookupMS       float64 `json:"dns_ms"`
+	ConnectMS         float64 `json:"connect_ms"`
+	TLSHandshakeMS    float64 `json:"tls_ms"`
+	TimeToFirstByteMS float64 `json:"ttfb_ms"`
+	ContentTransferMS float64 `json:"transfer_ms"`
+	TotalMS           float64 `json:"total_ms"`
+	ConnReused        bool    `json:"conn_reused"`
+}
+
+func newJSONTiming(t entity.Timing) jsonTiming {
+	return jsonTiming{
+		DNSLookupMS:       durationMS(t.DNSLookup),
+		ConnectMS:         durationMS(t.Connect),
+		TLSHandshakeMS:    durationMS(t.TLSHandshake),
+		TimeToFirstByteMS: durationMS(t.TimeToFirstByte),
+		ContentTransferMS: durationMS(t.ContentTransfer),
+		TotalMS:           durationMS(t.Total),
+		ConnReused:        t.ConnReused,
+	}
+}
+
+// jsonAttempt is a single try of the request.
+type jsonAttempt struct {
+	Number        int        `json:"number"`
+	Started       time.Time  `json:"started"`
+	StatusCode    int        `json:"status_code"`
+	Error         string     `json:"error,omitempty"`
+	Category      string     `json:"error_category,omitempty"`
+	BackoffMS     float64    `json:"backoff_ms"`
+	RetryAfterMS  float64    `json:"retry_after_ms,omitempty"`
+	WaitTruncated bool       `json:"wait_truncated,omitempty"`
+	Abandoned     bool       `json:"abandoned,omitempty"`
+	Timing        jsonTiming `json:"timing"`
+}
+
+func newJSONAttempts(attempts []entity.Attempt) []jsonAttempt {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	res := make([]jsonAttempt, 0, len(attempts))
+
+	for _, a := range attempts {
+		res = append(res, jsonAttempt{
+			Number:        a.Number,
+			Started:       a.Started,
+			StatusCode:    a.StatusCode,
+			Error:         a.Error,
+			Category:      string(a.ErrorCategory),
+			BackoffMS:     durationMS(a.Backoff),
+			RetryAfterMS:  durationMS(a.RetryAfter),
+			WaitTruncated: a.WaitTruncated,
+			Abandoned:     a.Abandoned,
+			Timing:        newJSONTiming(a.Timing),
+		})
+	}
+
+	return res
+}
+
+// jsonTLS is the connection and the peer chain, the leaf first.
+type jsonTLS struct {
+	Version     string     `json:"version"`
+	CipherSuite string     `json:"cipher_suite"`
+	ExpiresSoon bool       `json:"expires_soon,omitempty"`
+	Chain       []jsonCert `json:"chain"`
+}
+
+type jsonCert struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	SANs     []string  `json:"sans,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+	DaysLeft int       `json:"days_left"`
+}
+
+func newJSONTLS(info entity.TLSInfo) *jsonTLS {
+	if info.Version == "" {
+		return nil
+	}
+
+	res := &jsonTLS{
+		Version:     info.Version,
+		CipherSuite: info.CipherSuite,
+		ExpiresSoon: info.ExpiresSoon,
+		Chain:       make([]jsonCert, 0, len(info.Chain)),
+	}
+
+	for _, c := range info.Chain {
+		res.Chain = append(res.Chain, jsonCert(c))
+	}
+
+	return res
+}
+
+type jsonRedirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+func newJSONRedirects(redirects []entity.Redirect) []jsonRedirect {
+	if len(redirects) == 0 {
+		return nil
+	}
+
+	res := make([]jsonRedirect, 0, len(redirects))
+	for _, r := range redirects {
+		res = append(res, jsonRedirect(r))
+	}
+
+	return res
+}
+
+// jsonRecord is a flat machine readable representation of the task.
+type jsonRecord struct {
+	ID             string         `json:"id"`
+	URL            string         `json:"url"`
+	Method         string         `json:"method,omitempty"`
+	Tags           []string       `json:"tags,omitempty"`
+	StatusCode     int            `json:"status_code"`
+	ExpectedStatus int            `json:"expected_status,omitempty"`
+	ContentLength  int64          `json:"content_length"`
+	Content        string         `json:"content"`
+	Retries        int            `json:"retries"`
+	TimeStarted    time.Time      `json:"time_started"`
+	TimeCompleted  time.Time      `json:"time_completed"`
+	DurationMS     float64        `json:"duration_ms"`
+	Error          string         `json:"error,omitempty"`
+	ErrorCategory  string         `json:"error_category,omitempty"`
+	BodyPath       string         `json:"body_path,omitempty"`
+	BodySize       int64          `json:"body_size,omitempty"`
+	BodySHA256     string         `json:"body_sha256,omitempty"`
+	Timing         *jsonTiming    `json:"timing,omitempty"`
+	Proxy          string         `json:"proxy,omitempty"`
+	TLS            *jsonTLS       `json:"tls,omitempty"`
+	FinalURL       string         `json:"final_url,omitempty"`
+	Redirects      []jsonRedirect `json:"redirects,omitempty"`
+	Attempts       []jsonAttempt  `json:"attempts,omitempty"`
+}
+
+func newJSONRecord(task entity.Task) jsonRecord {
+	var timing *jsonTiming
+
+	if task.OutputParams.Timing.Total > 0 {
+		t := newJSONTiming(task.OutputParams.Timing)
+		timing = &t
+	}
+
+	return jsonRecord{
+		ID:             task.ID,
+		URL:            task.InputParams.URL,
+		Method:         task.InputParams.Method,
+		Tags:           task.InputParams.Tags,
+		StatusCode:     task.OutputParams.StatusCode,
+		ExpectedStatus: task.InputParams.ExpectedStatus,
+		ContentLength:  task.OutputParams.ContentLength,
+		Content:        task.OutputParams.Content,
+		Retries:        task.CurrentState.Retries,
+		TimeStarted:    task.OutputParams.TimeStarted,
+		TimeCompleted:  task.OutputParams.TimeCompleted,
+		DurationMS:     durationMS(task.Duration()),
+		Error:          task.OutputParams.Error,
+		ErrorCategory:  string(task.OutputParams.ErrorCategory),
+		BodyPath:       task.OutputParams.BodyPath,
+		BodySize:       task.OutputParams.BodySize,
+		BodySHA256:     task.OutputParams.BodySHA256,
+		Timing:         timing,
+		Proxy:          task.OutputParams.Proxy,
+		TLS:            newJSONTLS(task.OutputParams.TLS),
+		FinalURL:       task.OutputParams.FinalURL,
+		Redirects:      newJSONRedirects(task.OutputParams.Redirects),
+		Attempts:       newJSONAttempts(task.OutputParams.Attempts),
+	}
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// JSONLFormatter renders every task as a single line json object.
+type JSONLFormatter struct{}
+
+var _ Formatter = JSONLFormatter{}
+
+// Header -.
+func (JSONLFormatter) Header() string {
+	return ""
+}
+
+// Format -.
+func (JSONLFormatter) Format(task entity.Task) (string, error) {
+	b, err := json.Marshal(newJSONRecord(task))
+	if err != nil {
+		return "", err
+	}
+
+	return string(b) + "\n", nil
+}
+
+// Footer -.
+func (JSONLFormatter) Footer() string {
+	return ""
+}
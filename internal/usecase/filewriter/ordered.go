@@ -0,0 +1,85 @@
+package filewriter
+
+import (
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase"
+)
+
+const defaultReorderWindow = 1000
+
+// OrderedReader pops tasks in input order by their Seq. Tasks that came early
+// are buffered, but no more than window of them: when the buffer is full the
+// writer stops waiting for the gap and goes on with the next buffered task,
+// so memory stays bounded even if some task is very slow.
+// Tasks without Seq are passed through as is. It is not safe for concurrent use.
+type OrderedReader struct {
+	q       usecase.QueueReader
+	window  int
+	next    int
+	pending map[int]entity.Task
+}
+
+var _ usecase.QueueReader = (*OrderedReader)(nil)
+
+// NewOrderedReader wraps the queue, window less than 1 means default window.
+func NewOrderedReader(q usecase.QueueReader, window int) *OrderedReader {
+	if window < 1 {
+		window = defaultReorderWindow
+	}
+
+	return &OrderedReader{
+		q:       q,
+		window:  window,
+		next:    1,
+		pending: make(map[int]entity.Task, window),
+	}
+}
+
+// Pop -.
+func (or *OrderedReader) Pop() (entity.Task, bool) {
+	for {
+		if task, ok := or.pending[or.next]; ok {
+			delete(or.pending, or.next)
+			or.next++
+
+			return task, true
+		}
+
+		// buffer is full, give up on the gap
+		if len(or.pending) >= or.window {
+			or.next = or.minPending()
+			continue
+		}
+
+		task, ok := or.q.Pop()
+		if !ok {
+			if len(or.pending) == 0 {
+				return entity.Task{}, false
+			}
+
+			// queue is over, gaps will never be filled
+			or.next = or.minPending()
+
+			continue
+		}
+
+		// unordered or late task, its place is already passed
+		if task.Seq < or.next {
+			return task, true
+		}
+
+		or.pending[task.Seq] = task
+	}
+}
+
+func (or *OrderedReader) minPending() int {
+	res := 0
+
+	for seq := range or.pending {
+		if res == 0 || seq < res {
+			res = seq
+		}
+	}
+
+	return res
+}
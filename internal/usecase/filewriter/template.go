@@ -0,0 +1,94 @@
+package filewriter
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+)
+
+// templateFuncs are helpers available in output templates.
+var templateFuncs = template.FuncMap{
+	// ms returns duration in milliseconds
+	"ms": durationMS,
+	// seconds returns duration in seconds
+	"seconds": func(d time.Duration) float64 { return d.Seconds() },
+	// truncate cuts the string to n runes
+	"truncate": func(n int, s string) string {
+		if n < 0 || utf8.RuneCountInString(s) <= n {
+			return s
+		}
+
+		return string([]rune(s)[:n])
+	},
+	// oneline replaces line breaks with spaces
+	"oneline": func(s string) string {
+		return strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+	},
+	// json encodes any value, strings become quoted and escaped
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// header returns the first value of the header
+	"header": func(h http.Header, name string) string { return h.Get(name) },
+	"join":   func(sep string, s []string) string { return strings.Join(s, sep) },
+}
+
+// TemplateFormatter renders every task through text/template,
+// a line break is added when the template output has none at the end.
+type TemplateFormatter struct {
+	tmpl *template.Template
+}
+
+var _ Formatter = TemplateFormatter{}
+
+// NewTemplateFormatter parses the template, text started with @ is a path to the template file.
+func NewTemplateFormatter(text string) (TemplateFormatter, error) {
+	if path, ok := strings.CutPrefix(text, "@"); ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return TemplateFormatter{}, err
+		}
+
+		text = string(b)
+	}
+
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return TemplateFormatter{}, err
+	}
+
+	return TemplateFormatter{tmpl: tmpl}, nil
+}
+
+// Header -.
+func (TemplateFormatter) Header() string {
+	return ""
+}
+
+// Format -.
+func (f TemplateFormatter) Format(task entity.Task) (string, error) {
+	var sb strings.Builder
+
+	if err := f.tmpl.Execute(&sb, task); err != nil {
+		return "", err
+	}
+
+	s := sb.String()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+
+	return s, nil
+}
+
+// Footer -.
+func (TemplateFormatter) Footer() string {
+	return ""
+}
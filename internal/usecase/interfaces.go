@@ -2,6 +2,8 @@
 package usecase
 
 import (
+	"time"
+
 	"github.com/antonmisa/cliurlfetcher/internal/entity"
 )
 
@@ -26,6 +28,14 @@ type (
 		Push(entity.Task) error
 	}
 
+	// Limiter -.
+	Limiter interface {
+		// Acquire returns true when the task may be fetched now. Otherwise either
+		// the wait is returned, or the task is kept and given back by Release.
+		Acquire(entity.Task) (bool, time.Duration)
+		Release(entity.Task) (entity.Task, bool)
+	}
+
 	// StartShutdowner -.
 	StartStoper interface {
 		Start() error
@@ -0,0 +1,297 @@
+// Package limiter decides when a task may be fetched, to keep load on hosts polite.
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase"
+	"github.com/antonmisa/cliurlfetcher/pkg/hostmatch"
+	"golang.org/x/net/publicsuffix"
+)
+
+var ErrBadLimit = errors.New("bad limit")
+
+// HostRule overrides limits of the hosts matching the pattern, see hostmatch.Match.
+type HostRule struct {
+	Pattern     string
+	MaxInFlight int
+	Delay       time.Duration
+
+	// RPS is requests per second allowing Burst of requests at once
+	RPS   float64
+	Burst int
+}
+
+// HostOptions of the limiter, zero values mean no limit.
+type HostOptions struct {
+	// MaxInFlight requests to the same host
+	MaxInFlight int
+
+	// Delay between starts of requests to the same host
+	Delay time.Duration
+
+	// RPS per host with Burst of requests at once
+	RPS   float64
+	Burst int
+
+	// GlobalRPS of all requests with GlobalBurst of them at once
+	GlobalRPS   float64
+	GlobalBurst int
+
+	// MaxPause is the longest pause of a host asked by Retry-After,
+	// the same as the max Retry-After honored by retries
+	MaxPause time.Duration
+
+	// ByDomain limits registered domain (example.co.uk) instead of the host,
+	// rule patterns are matched against the domain then
+	ByDomain bool
+
+	// Rules are checked in order, the first matching wins
+	Rules []HostRule
+}
+
+// HostLimiter limits requests in flight and their rate per host. A task of the busy
+// host is kept by the limiter till a request to the host completes. The host rate is
+// adapted on 429 Too Many Requests, the host is paused as Retry-After says.
+type HostLimiter struct {
+	mu     sync.Mutex
+	opts   HostOptions
+	hosts  map[string]*hostState
+	global *bucket
+}
+
+type hostState struct {
+	limits    HostRule
+	inFlight  int
+	lastStart time.Time
+	parked    []entity.Task
+
+	// bucket is nil when rate is not limited
+	bucket *bucket
+
+	// pausedTill is set by Retry-After of 429 response
+	pausedTill time.Time
+}
+
+var _ usecase.Limiter = (*HostLimiter)(nil)
+
+func NewHostLimiter(opts HostOptions) (*HostLimiter, error) {
+	if opts.MaxInFlight < 0 || opts.Delay < 0 || opts.RPS < 0 || opts.Burst < 0 || opts.GlobalRPS < 0 || opts.GlobalBurst < 0 ||
+		opts.MaxPause < 0 {
+		return nil, fmt.Errorf("%w: negative default", ErrBadLimit)
+	}
+
+	for _, r := range opts.Rules {
+		if err := hostmatch.Validate(r.Pattern); err != nil {
+			return nil, err
+		}
+
+		if r.MaxInFlight < 0 || r.Delay < 0 || r.RPS < 0 || r.Burst < 0 {
+			return nil, fmt.Errorf("%w: negative value for %s", ErrBadLimit, r.Pattern)
+		}
+	}
+
+	hl := &HostLimiter{
+		opts:  opts,
+		hosts: make(map[string]*hostState),
+	}
+
+	if opts.GlobalRPS > 0 {
+		hl.global = newBucket(opts.GlobalRPS, opts.GlobalBurst, time.Now())
+	}
+
+	return hl, nil
+}
+
+// Acquire takes a slot of the task host. If the host is busy the task is kept
+// by the limiter and given back by Release. If the host or the global rate needs
+// a pause, the wait is returned and the task should come again after it.
+func (hl *HostLimiter) Acquire(task entity.Task) (bool, time.Duration) {
+	key := hl.key(task)
+
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	now := time.Now()
+
+	var wait time.Duration
+
+	h := hl.state(key, false)
+	if h != nil {
+		if h.limits.MaxInFlight > 0 && h.inFlight >= h.limits.MaxInFlight {
+			h.parked = append(h.parked, task)
+			return false, 0
+		}
+
+		if h.limits.Delay > 0 {
+			wait = maxWait(wait, h.lastStart.Add(h.limits.Delay).Sub(now))
+		}
+
+		wait = maxWait(wait, h.pausedTill.Sub(now))
+
+		if h.bucket != nil {
+			wait = maxWait(wait, h.bucket.wait(now))
+		}
+	}
+
+	if hl.global != nil {
+		wait = maxWait(wait, hl.global.wait(now))
+	}
+
+	if wait > 0 {
+		return false, wait
+	}
+
+	if h != nil {
+		h.inFlight++
+		h.lastStart = now
+
+		if h.bucket != nil {
+			h.bucket.take(now)
+		}
+	}
+
+	if hl.global != nil {
+		hl.global.take(now)
+	}
+
+	return true, 0
+}
+
+// Release frees the slot taken by Acquire, the next task kept for the host is returned if any.
+// The task is expected with its last attempt to adapt the host rate.
+func (hl *HostLimiter) Release(task entity.Task) (entity.Task, bool) {
+	key := hl.key(task)
+
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+
+	now := time.Now()
+
+	var last entity.Attempt
+	if n := len(task.OutputParams.Attempts); n > 0 {
+		last = task.OutputParams.Attempts[n-1]
+	}
+
+	// the host asked to slow down, it is tracked even if not limited
+	throttled := last.StatusCode == http.StatusTooManyRequests
+
+	h := hl.state(key, throttled)
+	if h == nil {
+		return entity.Task{}, false
+	}
+
+	if h.inFlight > 0 {
+		h.inFlight--
+	}
+
+	switch {
+	case throttled:
+		if pause := last.RetryAfter; pause > 0 {
+			if hl.opts.MaxPause > 0 && pause > hl.opts.MaxPause {
+				pause = hl.opts.MaxPause
+			}
+
+			h.pausedTill = maxTime(h.pausedTill, now.Add(pause))
+		}
+
+		if h.bucket != nil {
+			h.bucket.slowDown(now)
+		}
+	case last.StatusCode != 0 && h.bucket != nil:
+		h.bucket.speedUp(now)
+	}
+
+	if len(h.parked) == 0 {
+		return entity.Task{}, false
+	}
+
+	next := h.parked[0]
+	h.parked[0] = entity.Task{}
+	h.parked = h.parked[1:]
+
+	return next, true
+}
+
+// state returns state of the key, nil when the key is not limited unless force is set.
+func (hl *HostLimiter) state(key string, force bool) *hostState {
+	if h, ok := hl.hosts[key]; ok {
+		return h
+	}
+
+	limits := HostRule{
+		MaxInFlight: hl.opts.MaxInFlight,
+		Delay:       hl.opts.Delay,
+		RPS:         hl.opts.RPS,
+		Burst:       hl.opts.Burst,
+	}
+
+	for _, r := range hl.opts.Rules {
+		if hostmatch.Match(r.Pattern, key) {
+			limits = r
+			break
+		}
+	}
+
+	if limits.MaxInFlight == 0 && limits.Delay == 0 && limits.RPS == 0 && !force {
+		return nil
+	}
+
+	h := &hostState{limits: limits}
+
+	if limits.RPS > 0 {
+		h.bucket = newBucket(limits.RPS, limits.Burst, time.Now())
+	}
+
+	hl.hosts[key] = h
+
+	return h
+}
+
+// key returns host of the task url, or its registered domain.
+func (hl *HostLimiter) key(task entity.Task) string {
+	return Key(task.InputParams.URL, hl.opts.ByDomain)
+}
+
+// Key returns host of the url in lower case, or its registered domain if byDomain is set.
+// Hosts not having registered domain, like ip addresses and localhost, are kept as is.
+func Key(rawURL string, byDomain bool) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	if byDomain && net.ParseIP(host) == nil {
+		if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+			return domain
+		}
+	}
+
+	return host
+}
+
+func maxWait(a, b time.Duration) time.Duration {
+	if b > a {
+		return b
+	}
+
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostLimiter_MaxInFlight(t *testing.T) {
+	t.Parallel()
+
+	hl, err := NewHostLimiter(HostOptions{
+		MaxInFlight: 1,
+		Rules: []HostRule{
+			{Pattern: "*.example.com", MaxInFlight: 2},
+			{Pattern: "free.org"},
+		},
+	})
+	require.NoError(t, err)
+
+	a1 := entity.Constructor("1", "http://a.example.com/1", 1)
+	a2 := entity.Constructor("2", "http://A.example.com:8080/2", 1)
+	a3 := entity.Constructor("3", "http://a.example.com/3", 1)
+	other := entity.Constructor("4", "http://other.net/", 1)
+	other2 := entity.Constructor("5", "http://other.net/2", 1)
+
+	for _, task := range []entity.Task{a1, a2, other} {
+		ok, wait := hl.Acquire(task)
+		require.True(t, ok, task.ID)
+		require.Zero(t, wait, task.ID)
+	}
+
+	// both hosts are busy, tasks are kept
+	ok, wait := hl.Acquire(a3)
+	require.False(t, ok)
+	require.Zero(t, wait)
+
+	ok, _ = hl.Acquire(other2)
+	require.False(t, ok)
+
+	// not limited host
+	for i := 0; i < 3; i++ {
+		ok, _ = hl.Acquire(entity.Constructor("6", "https://free.org/", 1))
+		require.True(t, ok)
+	}
+
+	next, ok := hl.Release(a1)
+	require.True(t, ok)
+	require.Equal(t, a3, next)
+
+	_, ok = hl.Release(a2)
+	require.False(t, ok)
+
+	next, ok = hl.Release(other)
+	require.True(t, ok)
+	require.Equal(t, other2, next)
+
+	_, ok = hl.Release(entity.Constructor("6", "https://free.org/", 1))
+	require.False(t, ok)
+}
+
+func TestHostLimiter_Delay(t *testing.T) {
+	t.Parallel()
+
+	hl, err := NewHostLimiter(HostOptions{
+		Delay:    time.Hour,
+		ByDomain: true,
+	})
+	require.NoError(t, err)
+
+	ok, _ := hl.Acquire(entity.Constructor("1", "http://www.example.co.uk/", 1))
+	require.True(t, ok)
+
+	// the same registered domain has to wait
+	ok, wait := hl.Acquire(entity.Constructor("2", "http://api.example.co.uk/", 1))
+	require.False(t, ok)
+	require.InDelta(t, time.Hour, wait, float64(time.Second))
+
+	ok, _ = hl.Acquire(entity.Constructor("3", "http://example.com/", 1))
+	require.True(t, ok)
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url      string
+		byDomain bool
+		want     string
+	}{
+		{url: "https://WWW.Example.com:443/path", want: "www.example.com"},
+		{url: "https://www.example.com/", byDomain: true, want: "example.com"},
+		{url: "https://a.b.example.co.uk/", byDomain: true, want: "example.co.uk"},
+		{url: "http://127.0.0.1:8080/", byDomain: true, want: "127.0.0.1"},
+		{url: "http://localhost/", byDomain: true, want: "localhost"},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.want, Key(tc.url, tc.byDomain), tc.url)
+	}
+}
+
+func TestNewHostLimiter(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewHostLimiter(HostOptions{MaxInFlight: -1})
+	require.ErrorIs(t, err, ErrBadLimit)
+
+	_, err = NewHostLimiter(HostOptions{Rules: []HostRule{{Pattern: "a.*.com"}}})
+	require.Error(t, err)
+}
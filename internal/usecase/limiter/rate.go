@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// slowDownFactor divides the rate on 429 Too Many Requests, speedUpStep is
+	// a part of the configured rate restored on every success
+	slowDownFactor = 2
+	speedUpStep    = 0.1
+	minRatePart    = 1.0 / 16
+)
+
+// bucket is a token bucket allowing rate requests per second with bursts of burst requests.
+// The rate is adapted to the host, it is lowered on 429 and restored on success.
+type bucket struct {
+	rate    float64
+	current float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+}
+
+func newBucket(rate float64, burst int, now time.Time) *bucket {
+	b := math.Max(float64(burst), 1)
+
+	return &bucket{
+		rate:    rate,
+		current: rate,
+		burst:   b,
+		tokens:  b,
+		last:    now,
+	}
+}
+
+// wait returns time till the next token, zero if it is available now.
+func (b *bucket) wait(now time.Time) time.Duration {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - b.tokens) / b.current * float64(time.Second))
+}
+
+func (b *bucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.current)
+		b.last = now
+	}
+}
+
+// slowDown is called on 429 Too Many Requests, the bucket is emptied.
+func (b *bucket) slowDown(now time.Time) {
+	b.refill(now)
+	b.current = math.Max(b.current/slowDownFactor, b.rate*minRatePart)
+	b.tokens = math.Min(b.tokens, 0)
+}
+
+func (b *bucket) speedUp(now time.Time) {
+	b.refill(now)
+	b.current = math.Min(b.current+b.rate*speedUpStep, b.rate)
+}
@@ -0,0 +1,149 @@
+package limiter
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	b := newBucket(10, 2, now)
+
+	// burst is available at once
+	for i := 0; i < 2; i++ {
+		require.Zero(t, b.wait(now))
+		b.take(now)
+	}
+
+	require.InDelta(t, 100*time.Millisecond, b.wait(now), float64(time.Millisecond))
+
+	// refilled, but never above burst
+	later := now.Add(time.Hour)
+	require.Zero(t, b.wait(later))
+	require.InDelta(t, 2, b.tokens, 0.001)
+
+	b.slowDown(later)
+	require.InDelta(t, 5, b.current, 0.001)
+	require.InDelta(t, 200*time.Millisecond, b.wait(later), float64(time.Millisecond))
+
+	for i := 0; i < 10; i++ {
+		b.slowDown(later)
+	}
+
+	require.InDelta(t, 10*minRatePart, b.current, 0.001)
+
+	for i := 0; i < 20; i++ {
+		b.speedUp(later)
+	}
+
+	require.InDelta(t, 10, b.current, 0.001)
+}
+
+func TestHostLimiter_RPS(t *testing.T) {
+	t.Parallel()
+
+	hl, err := NewHostLimiter(HostOptions{
+		RPS:       1,
+		GlobalRPS: 1000,
+		Rules: []HostRule{
+			{Pattern: "fast.org", RPS: 1000, Burst: 3},
+		},
+	})
+	require.NoError(t, err)
+
+	ok, _ := hl.Acquire(entity.Constructor("1", "http://example.com/1", 1))
+	require.True(t, ok)
+
+	ok, wait := hl.Acquire(entity.Constructor("2", "http://example.com/2", 1))
+	require.False(t, ok)
+	require.InDelta(t, time.Second, wait, float64(10*time.Millisecond))
+
+	// the global bucket has burst of one
+	ok, wait = hl.Acquire(entity.Constructor("3", "http://fast.org/", 1))
+	require.False(t, ok)
+	require.Positive(t, wait)
+
+	time.Sleep(wait)
+
+	ok, _ = hl.Acquire(entity.Constructor("3", "http://fast.org/", 1))
+	require.True(t, ok)
+}
+
+func TestHostLimiter_TooManyRequests(t *testing.T) {
+	t.Parallel()
+
+	hl, err := NewHostLimiter(HostOptions{
+		Rules: []HostRule{
+			{Pattern: "quota.org", RPS: 100, Burst: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	throttled := func(task entity.Task, retryAfter time.Duration) entity.Task {
+		task.OutputParams.Attempts = []entity.Attempt{{
+			Number:     1,
+			StatusCode: http.StatusTooManyRequests,
+			RetryAfter: retryAfter,
+		}}
+
+		return task
+	}
+
+	// not limited host is paused as Retry-After says
+	free := entity.Constructor("1", "http://free.org/", 1)
+
+	ok, _ := hl.Acquire(free)
+	require.True(t, ok)
+
+	_, ok = hl.Release(throttled(free, time.Hour))
+	require.False(t, ok)
+
+	ok, wait := hl.Acquire(free)
+	require.False(t, ok)
+	require.InDelta(t, time.Hour, wait, float64(time.Second))
+
+	// limited host is slowed down
+	quota := entity.Constructor("2", "http://quota.org/", 1)
+
+	ok, _ = hl.Acquire(quota)
+	require.True(t, ok)
+
+	hl.Release(throttled(quota, 0))
+
+	ok, wait = hl.Acquire(quota)
+	require.False(t, ok)
+	require.InDelta(t, 20*time.Millisecond, wait, float64(2*time.Millisecond))
+}
+
+func TestHostLimiter_MaxPause(t *testing.T) {
+	t.Parallel()
+
+	hl, err := NewHostLimiter(HostOptions{MaxInFlight: 1, MaxPause: time.Minute})
+	require.NoError(t, err)
+
+	task := entity.Constructor("1", "http://slow.org/", 1)
+
+	ok, _ := hl.Acquire(task)
+	require.True(t, ok)
+
+	// Retry-After: 86400 does not stop the host for a day
+	task.OutputParams.Attempts = []entity.Attempt{{
+		Number:     1,
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: 24 * time.Hour,
+	}}
+	hl.Release(task)
+
+	ok, wait := hl.Acquire(task)
+	require.False(t, ok)
+	require.InDelta(t, time.Minute, wait, float64(time.Second))
+
+	_, err = NewHostLimiter(HostOptions{MaxPause: -time.Second})
+	require.ErrorIs(t, err, ErrBadLimit)
+}
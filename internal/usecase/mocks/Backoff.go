@@ -14,13 +14,13 @@ type Backoff struct {
 	mock.Mock
 }
 
-// Execute provides a mock function with given fields: min, max, attemptNum, resp
-func (_m *Backoff) Execute(min time.Duration, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
-	ret := _m.Called(min, max, attemptNum, resp)
+// Execute provides a mock function with given fields: min, max, attemptNum, prev, resp
+func (_m *Backoff) Execute(min time.Duration, max time.Duration, attemptNum int, prev time.Duration, resp *http.Response) time.Duration {
+	ret := _m.Called(min, max, attemptNum, prev, resp)
 
 	var r0 time.Duration
-	if rf, ok := ret.Get(0).(func(time.Duration, time.Duration, int, *http.Response) time.Duration); ok {
-		r0 = rf(min, max, attemptNum, resp)
+	if rf, ok := ret.Get(0).(func(time.Duration, time.Duration, int, time.Duration, *http.Response) time.Duration); ok {
+		r0 = rf(min, max, attemptNum, prev, resp)
 	} else {
 		r0 = ret.Get(0).(time.Duration)
 	}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/antonmisa/cliurlfetcher/internal/usecase"
+)
+
+// DelayQueue gives tasks out in order of their CurrentState.NotBefore, not earlier
+// than it comes. Tasks with the same time are given in input order.
+type DelayQueue struct {
+	mu     sync.Mutex
+	tasks  taskHeap
+	closed bool
+
+	// changed is closed and replaced on every push and close to wake up waiting readers
+	changed chan struct{}
+}
+
+var _ usecase.Queue = (*DelayQueue)(nil)
+
+func NewDelayQueue() *DelayQueue {
+	return &DelayQueue{
+		changed: make(chan struct{}),
+	}
+}
+
+// Push never blocks.
+func (dq *DelayQueue) Push(t entity.Task) error {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+
+	if dq.closed {
+		return ErrTaskQueueClosed
+	}
+
+	heap.Push(&dq.tasks, t)
+	dq.notify()
+
+	return nil
+}
+
+// Pop waits for the earliest task to become due. After Close the rest of tasks
+// are given without waiting, false is returned when the queue is closed and empty.
+func (dq *DelayQueue) Pop() (entity.Task, bool) {
+	for {
+		dq.mu.Lock()
+
+		if dq.tasks.Len() == 0 && dq.closed {
+			dq.mu.Unlock()
+			return entity.Task{}, false
+		}
+
+		var wait time.Duration
+
+		if dq.tasks.Len() > 0 {
+			wait = time.Until(dq.tasks[0].CurrentState.NotBefore)
+
+			if wait <= 0 || dq.closed {
+				t, _ := heap.Pop(&dq.tasks).(entity.Task)
+				dq.mu.Unlock()
+
+				return t, true
+			}
+		}
+
+		changed := dq.changed
+		dq.mu.Unlock()
+
+		if wait == 0 {
+			<-changed
+			continue
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Len returns number of tasks in the queue, due or not.
+func (dq *DelayQueue) Len() int {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+
+	return dq.tasks.Len()
+}
+
+// Close could be called more than once.
+func (dq *DelayQueue) Close() {
+	dq.mu.Lock()
+	defer dq.mu.Unlock()
+
+	if dq.closed {
+		return
+	}
+
+	dq.closed = true
+	dq.notify()
+}
+
+func (dq *DelayQueue) notify() {
+	close(dq.changed)
+	dq.changed = make(chan struct{})
+}
+
+// taskHeap implements heap.Interface ordered by NotBefore and Seq.
+type taskHeap []entity.Task
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool {
+	a, b := h[i].CurrentState.NotBefore, h[j].CurrentState.NotBefore
+	if !a.Equal(b) {
+		return a.Before(b)
+	}
+
+	return h[i].Seq < h[j].Seq
+}
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x any) {
+	t, _ := x.(entity.Task)
+	*h = append(*h, t)
+}
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = entity.Task{}
+	*h = old[:n-1]
+
+	return t
+}
@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antonmisa/cliurlfetcher/internal/entity"
+	"github.com/stretchr/testify/require"
+)
+
+func delayedTask(id string, seq int, notBefore time.Time) entity.Task {
+	t := entity.Constructor(id, "http://www.yandex.ru", 1)
+	t.Seq = seq
+	t.CurrentState.NotBefore = notBefore
+
+	return t
+}
+
+func TestDelayQueue_Pop(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	dq := NewDelayQueue()
+
+	require.NoError(t, dq.Push(delayedTask("late", 1, now.Add(50*time.Millisecond))))
+	require.NoError(t, dq.Push(delayedTask("second", 3, now)))
+	require.NoError(t, dq.Push(delayedTask("first", 2, now)))
+	require.Equal(t, 3, dq.Len())
+
+	// due tasks come in order of time and input
+	for _, id := range []string{"first", "second"} {
+		task, ok := dq.Pop()
+		require.True(t, ok)
+		require.Equal(t, id, task.ID)
+	}
+
+	// not due task is waited for
+	task, ok := dq.Pop()
+	require.True(t, ok)
+	require.Equal(t, "late", task.ID)
+	require.False(t, time.Now().Before(now.Add(50*time.Millisecond)))
+}
+
+func TestDelayQueue_PushWakesWaiting(t *testing.T) {
+	t.Parallel()
+
+	dq := NewDelayQueue()
+
+	require.NoError(t, dq.Push(delayedTask("late", 1, time.Now().Add(time.Hour))))
+
+	popped := make(chan entity.Task)
+
+	go func() {
+		task, _ := dq.Pop()
+		popped <- task
+	}()
+
+	// reader waiting for the late task gets the earlier one pushed later
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, dq.Push(delayedTask("now", 2, time.Now())))
+
+	select {
+	case task := <-popped:
+		require.Equal(t, "now", task.ID)
+	case <-time.After(time.Second):
+		require.Fail(t, "pop is not woken up by push")
+	}
+}
+
+func TestDelayQueue_Close(t *testing.T) {
+	t.Parallel()
+
+	dq := NewDelayQueue()
+
+	require.NoError(t, dq.Push(delayedTask("late", 1, time.Now().Add(time.Hour))))
+
+	dq.Close()
+	dq.Close()
+
+	require.ErrorIs(t, dq.Push(delayedTask("1", 2, time.Time{})), ErrTaskQueueClosed)
+
+	// the rest is given without waiting
+	task, ok := dq.Pop()
+	require.True(t, ok)
+	require.Equal(t, "late", task.ID)
+
+	_, ok = dq.Pop()
+	require.False(t, ok)
+}
@@ -0,0 +1,41 @@
+// Package hostmatch matches host names against patterns used in config.
+package hostmatch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrBadPattern = errors.New("bad host pattern")
+
+// Match reports whether the host matches the pattern. The pattern is either
+// a host name "api.example.com", "*.example.com" matching any subdomain of
+// example.com but not itself, or "*" matching any host. Case is ignored.
+func Match(pattern, host string) bool {
+	pattern, host = normalize(pattern), normalize(host)
+
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	default:
+		return pattern == host
+	}
+}
+
+// Validate returns error when the pattern could never match.
+func Validate(pattern string) error {
+	p := normalize(pattern)
+
+	if p == "" || strings.Contains(p[1:], "*") || (strings.HasPrefix(p, "*") && p != "*" && !strings.HasPrefix(p, "*.")) {
+		return fmt.Errorf("%w: %q", ErrBadPattern, pattern)
+	}
+
+	return nil
+}
+
+func normalize(s string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+}
@@ -0,0 +1,77 @@
+// Package httpheader parses headers given in config, flags and input files.
+package httpheader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var ErrBadHeader = errors.New("bad header")
+
+// Parse accepts either a json object, see ParseJSON, or "Name: value" lines
+// delimited by new lines, see ParseLines. Empty string means no headers.
+func Parse(s string) (http.Header, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		return ParseJSON([]byte(s))
+	}
+
+	return ParseLines(strings.Split(s, "\n"))
+}
+
+// ParseLines parses "Name: value" lines, the same name could be repeated.
+// Blank lines are skipped, nil is returned when there are no headers.
+func ParseLines(lines []string) (http.Header, error) {
+	var headers http.Header
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%w: %q", ErrBadHeader, line)
+		}
+
+		if headers == nil {
+			headers = http.Header{}
+		}
+
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return headers, nil
+}
+
+// ParseJSON accepts {"Name": "value"} as well as {"Name": ["v1", "v2"]}.
+func ParseJSON(raw []byte) (http.Header, error) {
+	var single map[string]string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		headers := make(http.Header, len(single))
+
+		for k, v := range single {
+			headers.Add(k, v)
+		}
+
+		return headers, nil
+	}
+
+	var multi map[string][]string
+	if err := json.Unmarshal(raw, &multi); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadHeader, err)
+	}
+
+	headers := make(http.Header, len(multi))
+
+	for k, vs := range multi {
+		for _, v := range vs {
+			headers.Add(k, v)
+		}
+	}
+
+	return headers, nil
+}